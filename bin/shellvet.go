@@ -0,0 +1,16 @@
+// +build ignore
+
+// shellvet checks the scripts passed to the shell package. Run it with go vet:
+//
+//   go build -o /tmp/shellvet bin/shellvet.go
+//   go vet -vettool=/tmp/shellvet ./...
+package main
+
+import (
+	"github.com/justjake/go-scripting/shell/shellvet"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(shellvet.Analyzer)
+}
@@ -103,8 +103,41 @@ func (c *staticCompose) NewName() string {
 	return fmt.Sprintf(c.OuterAppend(), c.InnerName())
 }
 
+// OuterArgsDecl declares the parameters of the composed function: any
+// parameters of the inner function after the first, followed by the
+// parameters of the outer function.
 func (c *staticCompose) OuterArgsDecl() string {
-	return funcFieldListString(c.fset, c.outer.FuncDecl.Type.Params)
+	params := &ast.FieldList{}
+	params.List = append(params.List, c.innerExtraParams()...)
+	params.List = append(params.List, c.outer.FuncDecl.Type.Params.List...)
+	return funcFieldListString(c.fset, params)
+}
+
+// innerExtraParams returns the parameters of the inner function after the
+// first, which is the one that receives the outer function's result.
+func (c *staticCompose) innerExtraParams() []*ast.Field {
+	params := c.inner.FuncDecl.Type.Params.List
+	if len(params) == 0 {
+		return nil
+	}
+	if len(params[0].Names) > 1 {
+		rest := *params[0]
+		rest.Names = rest.Names[1:]
+		return append([]*ast.Field{&rest}, params[1:]...)
+	}
+	return params[1:]
+}
+
+// InnerExtraArgs passes the inner function's extra parameters through to it.
+func (c *staticCompose) InnerExtraArgs() string {
+	out := new(bytes.Buffer)
+	for _, f := range c.innerExtraParams() {
+		for _, name := range f.Names {
+			out.WriteString(", ")
+			out.WriteString(name.String())
+		}
+	}
+	return out.String()
 }
 
 func (c *staticCompose) InnerReturnDecl() string {
@@ -122,6 +155,15 @@ func (c *staticCompose) OuterName() string {
 	return c.outer.FuncDecl.Name.String()
 }
 
+// OuterCall is how the composed function calls the outer function. An outer
+// method is called on the inner function's receiver.
+func (c *staticCompose) OuterCall() string {
+	if c.outer.FuncDecl.Recv == nil {
+		return c.OuterName()
+	}
+	return c.InnerRecv() + c.OuterName()
+}
+
 func (c *staticCompose) OuterArgsList() string {
 	out := new(bytes.Buffer)
 	fields := c.outer.FuncDecl.Type.Params.List
@@ -137,6 +179,19 @@ func (c *staticCompose) OuterArgsList() string {
 	return out.String()
 }
 
+// InnerSpread is "..." if the inner function's only parameter is variadic, so
+// that the outer function's slice result is spread into its arguments.
+func (c *staticCompose) InnerSpread() string {
+	params := c.inner.FuncDecl.Type.Params.List
+	if len(params) != 1 {
+		return ""
+	}
+	if _, ok := params[0].Type.(*ast.Ellipsis); ok {
+		return "..."
+	}
+	return ""
+}
+
 func (c *staticCompose) Render() string {
 	out := new(bytes.Buffer)
 	err := tmpl.Execute(out, c)
@@ -147,9 +202,9 @@ func (c *staticCompose) Render() string {
 }
 
 const composed = `
-// {{.NewName}} is equivalent to {{.InnerRecv}}{{.InnerName}}({{.OuterName}}({{.OuterArgsList}}))
+// {{.NewName}} is equivalent to {{.InnerRecv}}{{.InnerName}}({{.OuterCall}}({{.OuterArgsList}}){{.InnerSpread}}{{.InnerExtraArgs}})
 func {{.InnerRecvDecl}} {{.NewName}}{{.OuterArgsDecl}} {{.InnerReturnDecl}} {
-	return {{.InnerRecv}}{{.InnerName}}({{.OuterName}}({{.OuterArgsList}}))
+	return {{.InnerRecv}}{{.InnerName}}({{.OuterCall}}({{.OuterArgsList}}){{.InnerSpread}}{{.InnerExtraArgs}})
 }
 `
 
@@ -3,6 +3,7 @@ package env
 import (
 	"fmt"
 	"os"
+	"sort"
 )
 
 // Vars is an in-process store for string key-value pairs that fall back to the
@@ -97,3 +98,20 @@ func (vars *Vars) Lookup(name string) (val interface{}, err error) {
 	}
 	return stringVal, nil
 }
+
+// Environ returns the Locals as "key=value" strings, like os.Environ. This
+// implements shell.Environer, so a Vars can be used as a shell's Env to export
+// its Locals to scripts.
+func (vars *Vars) Environ() []string {
+	names := make([]string, 0, len(vars.Locals))
+	for name := range vars.Locals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	environ := make([]string, len(names))
+	for i, name := range names {
+		environ[i] = name + "=" + vars.Locals[name]
+	}
+	return environ
+}
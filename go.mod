@@ -1,12 +1,23 @@
 module github.com/justjake/go-scripting
 
+go 1.24
+
 require (
-	github.com/davecgh/go-spew v1.1.0 // indirect
 	github.com/iancoleman/strcase v0.0.0-20180726023541-3605ed457bf7
 	github.com/kballard/go-shellquote v0.0.0-20180428030007-95032a82bc51
-	github.com/kr/pretty v0.1.0
-	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/rjeczalik/interfaces v0.0.0-20180827192841-185b87db4c04 // indirect
+	github.com/kr/pretty v0.3.1
 	github.com/stretchr/testify v1.2.2
-	golang.org/x/tools v0.0.0-20181006002542-f60d9635b16a // indirect
+	golang.org/x/tools v0.28.0
+	mvdan.cc/sh/v3 v3.11.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/kr/text v0.2.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/rogpeppe/go-internal v1.14.1 // indirect
+	golang.org/x/mod v0.22.0 // indirect
+	golang.org/x/sync v0.10.0 // indirect
+	golang.org/x/sys v0.30.0 // indirect
+	golang.org/x/term v0.29.0 // indirect
 )
@@ -0,0 +1,155 @@
+package shell
+
+// Methods for running commands directly from an argument list, without
+// starting a shell to interpret a script.
+
+import (
+	"fmt"
+	"os/exec"
+
+	shellquote "github.com/kballard/go-shellquote"
+)
+
+// ExecCmd returns an exec.Cmd that runs argv[0] with the arguments argv[1:].
+// No shell is involved, so the arguments are never subject to word splitting,
+// globbing, or variable expansion. ExecCmd panics if argv is empty.
+//
+// Like Cmd, the returned command does not have Stdout or Stderr assigned.
+//
+// @StaticCompose.Inside("argv")
+func (sh *Shell) ExecCmd(argv ...string) *exec.Cmd {
+	if len(argv) == 0 {
+		panic(fmt.Errorf("ExecCmd: argv must not be empty"))
+	}
+	if sh.ctx != nil {
+		return sh.prepare(exec.CommandContext(sh.ctx, argv[0], argv[1:]...))
+	}
+	return sh.prepare(exec.Command(argv[0], argv[1:]...))
+}
+
+// ExecDo runs the given command to completion and returns a Result describing
+// the process. It is the argv equivalent of Do. The Result's Script is the
+// command quoted with shellquote.Join.
+//
+// @StaticCompose.Inside("argv")
+func (sh *Shell) ExecDo(argv ...string) *Result {
+	return sh.do(sh.argvCmd(argv), argvScript(argv), nil, nil)
+}
+
+// Exec runs the given command to completion. It is the argv equivalent of Run.
+//
+//   sh.Exec("git", "log", "--oneline")
+//
+// @StaticCompose.Inside("argv")
+func (sh *Shell) Exec(argv ...string) error {
+	return sh.do(sh.argvCmd(argv), argvScript(argv), sh.Stdout, sh.Stderr).Err()
+}
+
+// ExecOut captures the Stdout of a command and returns it as a string, minus
+// the last trailing newline. It is the argv equivalent of Out.
+//
+// @StaticCompose.Inside("argv")
+func (sh *Shell) ExecOut(argv ...string) string {
+	return sh.do(sh.argvCmd(argv), argvScript(argv), nil, sh.Stderr).Stdout
+}
+
+// ExecOutStatus captures the Stdout of a command and returns it as a string,
+// minus the last trailing newline. If an error occurs or the command exits
+// non-zero, a non-nil error is returned. It is the argv equivalent of
+// OutStatus.
+//
+// @StaticCompose.Inside("argv")
+func (sh *Shell) ExecOutStatus(argv ...string) (string, error) {
+	res := sh.do(sh.argvCmd(argv), argvScript(argv), nil, sh.Stderr)
+	return res.Stdout, res.Err()
+}
+
+// ExecOutErrStatus captures the Stdout and Stderr of a command and returns
+// each as a string, minus the last trailing newline. It is the argv equivalent
+// of OutErrStatus.
+//
+// @StaticCompose.Inside("argv")
+func (sh *Shell) ExecOutErrStatus(argv ...string) (string, string, error) {
+	res := sh.do(sh.argvCmd(argv), argvScript(argv), nil, nil)
+	return res.Stdout, res.Stderr, res.Err()
+}
+
+// ExecSucceeds runs the command and returns true if it exited 0, or false
+// otherwise. It is the argv equivalent of Succeeds.
+//
+// @StaticCompose.Inside("argv")
+func (sh *Shell) ExecSucceeds(argv ...string) bool {
+	return sh.Exec(argv...) == nil
+}
+
+// ArgvPrint builds an argument list for Exec. Each value becomes exactly one
+// argument, converted to a string with fmt.Sprint. Raw values are split into
+// several arguments using shell word splitting rules, but no expansion is
+// performed. Slices, maps and Argers become an argument for each of their
+// words.
+//
+//   ArgvPrint(Raw("git log -n"), 5, "--", filenames)
+//
+// @StaticCompose.Group("argv", "%sp")
+func ArgvPrint(vs ...interface{}) []string {
+	argv := make([]string, 0, len(vs))
+	for _, v := range vs {
+		switch v := v.(type) {
+		case Raw:
+			argv = append(argv, splitArgv(string(v))...)
+			continue
+		case Escaper:
+			argv = append(argv, splitArgv(string(v.Escape()))...)
+			continue
+		case []byte:
+			argv = append(argv, string(v))
+			continue
+		}
+		if words, ok := expand(v); ok {
+			argv = append(argv, ArgvPrint(words...)...)
+			continue
+		}
+		argv = append(argv, fmt.Sprint(v))
+	}
+	return argv
+}
+
+// ArgvPrintf builds an argument list for Exec from a format string. The script
+// is formatted with ScriptPrintf, and then split into words, so each non-Raw
+// value will be exactly one argument.
+//
+//   ArgvPrintf(`git log -n %s -- %s`, 5, filename)
+//
+// @StaticCompose.Group("argv", "%sf")
+func ArgvPrintf(scriptformat string, vs ...interface{}) []string {
+	return splitArgv(ScriptPrintf(scriptformat, vs...))
+}
+
+// ArgvTemplate builds an argument list for Exec from a template. The script is
+// rendered with ScriptTemplate, and then split into words, so each non-raw
+// expansion will be exactly one argument.
+//
+// @StaticCompose.Group("argv", "%st")
+func ArgvTemplate(template string, vars Lookuper) []string {
+	return splitArgv(ScriptTemplate(template, vars))
+}
+
+// argvCmd returns a function that makes a new command for argv each time it
+// is called.
+func (sh *Shell) argvCmd(argv []string) func() *exec.Cmd {
+	return func() *exec.Cmd {
+		return sh.ExecCmd(argv...)
+	}
+}
+
+func argvScript(argv []string) string {
+	return shellquote.Join(argv...)
+}
+
+func splitArgv(script string) []string {
+	argv, err := shellquote.Split(script)
+	if err != nil {
+		panic(fmt.Errorf("Could not split %q into arguments: %v", script, err))
+	}
+	return argv
+}
@@ -0,0 +1,75 @@
+package shell
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestArgvPrint(t *testing.T) {
+	cases := []struct {
+		in  []interface{}
+		out []string
+	}{
+		{[]interface{}{"echo", "foo bar"}, []string{"echo", "foo bar"}},
+		{[]interface{}{Raw("git log -n"), 5, "--", "$file"}, []string{"git", "log", "-n", "5", "--", "$file"}},
+		{[]interface{}{Raw("echo 'a b'"), "; rm -rf /"}, []string{"echo", "a b", "; rm -rf /"}},
+		{[]interface{}{"rm", []string{"a b", "c"}}, []string{"rm", "a b", "c"}},
+		{[]interface{}{"docker", "run", Flags(Vars{"rm": true, "name": "x"})}, []string{"docker", "run", "--name=x", "--rm"}},
+	}
+
+	for _, c := range cases {
+		actual := ArgvPrint(c.in...)
+		if !reflect.DeepEqual(actual, c.out) {
+			t.Errorf("ArgvPrint(%#v...) -> %#v != %#v", c.in, actual, c.out)
+		}
+	}
+}
+
+func TestArgvPrintf(t *testing.T) {
+	cases := []struct {
+		format string
+		vs     []interface{}
+		out    []string
+	}{
+		{"echo %s", []interface{}{"foo bar"}, []string{"echo", "foo bar"}},
+		{"echo %s %s", []interface{}{"$first $last", Raw("a b")}, []string{"echo", "$first $last", "a", "b"}},
+	}
+	for _, c := range cases {
+		actual := ArgvPrintf(c.format, c.vs...)
+		if !reflect.DeepEqual(actual, c.out) {
+			t.Errorf("ArgvPrintf(%#v, %#v...) -> %#v != %#v", c.format, c.vs, actual, c.out)
+		}
+	}
+}
+
+func TestExecOut(t *testing.T) {
+	sh := &Shell{}
+	out := sh.ExecOutp("echo", "$HOME; exit 1")
+	if out != "$HOME; exit 1" {
+		t.Errorf("ExecOutp -> %q", out)
+	}
+	if sh.ExecSucceeds("false") {
+		t.Errorf("ExecSucceeds(false) -> true")
+	}
+	if sh.LastError() == nil || sh.LastError().ExitCode() != 1 {
+		t.Errorf("LastError() -> %v, expected exit status 1", sh.LastError())
+	}
+}
+
+func TestMockShellExec(t *testing.T) {
+	sh := &MockShell{}
+	sh.AddMock(MockCall{Script: "git status", Stdout: "clean"})
+	sh.AddMock(MockCall{Script: "git push", ExitStatus: 1})
+
+	// A nil *ExitError returned as an error would not be == nil.
+	if out, err := sh.ExecOutStatus("git", "status"); out != "clean" || err != nil {
+		t.Errorf("ExecOutStatus(git status) -> %q, %#v", out, err)
+	}
+	if err := sh.Exec("git", "push"); err == nil {
+		t.Errorf("Exec(git push) -> nil error")
+	}
+	sh.AddMock(MockCall{Script: "git status"})
+	if _, _, err := sh.ExecOutErrStatus("git", "status"); err != nil {
+		t.Errorf("ExecOutErrStatus(git status) -> %#v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package shell
+
+// Cassettes record the scripts run by a real Shell, so that a MockShell can
+// replay them later.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+// CassetteCall is a script recorded in a Cassette.
+type CassetteCall struct {
+	MockCall
+	Dir string   `json:",omitempty"`
+	Env []string `json:",omitempty"`
+}
+
+// Cassette is a recording of the scripts run by a Shell, in order. To record a
+// cassette, use it as a Shell's Tracer, and then Save it. To replay it, Load it
+// and pass it to MockShell.Replay.
+//
+//   // Record real output once:
+//   cassette := &Cassette{}
+//   sh := &Shell{Trace: cassette}
+//   deploy(sh)
+//   cassette.Save("testdata/deploy.json")
+//
+//   // Then, in tests:
+//   cassette, err := LoadCassette("testdata/deploy.json")
+//   sh := (&MockShell{}).Replay(cassette)
+//   deploy(sh)
+//
+// A Cassette receives the output of scripts without TraceOutput. Only captured
+// output, and the lines read with Lines or Stream, is recorded, so output of
+// methods like Run that is connected to the Shell's Stdout or Stderr will be
+// empty on replay. Secrets are masked in the
+// recording, so scripts that contain them can't be replayed.
+//
+// On replay, each script must also run in the Dir and with the Env it was
+// recorded with. A Pipeline is recorded and replayed as a whole, without
+// running any of its stages.
+type Cassette struct {
+	Calls []CassetteCall
+	mu    sync.Mutex
+	// Replay progress
+	next int
+}
+
+// LoadCassette reads a cassette saved with Cassette.Save.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cassette{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("Could not load cassette %s: %v", path, err)
+	}
+	return c, nil
+}
+
+// Trace implements Tracer by recording each finished script. A script that
+// is retried is recorded once, with the result of its last attempt, since a
+// MockShell doesn't retry.
+func (c *Cassette) Trace(event TraceEvent) {
+	if event.Kind != TraceFinish {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	call := CassetteCall{
+		MockCall: MockCall{
+			Script:     event.Script,
+			ExitStatus: event.ExitCode,
+			Stdout:     event.Stdout,
+			Stderr:     event.Stderr,
+		},
+		Dir: event.Dir,
+		Env: event.Env,
+	}
+	if event.Attempt > 1 {
+		for i := len(c.Calls) - 1; i >= 0; i-- {
+			if c.Calls[i].Script == call.Script && c.Calls[i].Dir == call.Dir {
+				c.Calls[i] = call
+				return
+			}
+		}
+	}
+	c.Calls = append(c.Calls, call)
+}
+
+// Save writes the cassette to path as JSON.
+func (c *Cassette) Save(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// pop returns the next call in the cassette, and panics if it isn't for the
+// given script, run in dir with env added to its environment.
+func (c *Cassette) pop(script string, dir string, env []string) *MockCall {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.next >= len(c.Calls) {
+		panic(fmt.Errorf("Cassette has no more calls (played %d), but got script:\n%s", c.next, script))
+	}
+	recorded := c.Calls[c.next]
+	call := recorded.MockCall
+	if call.Script != script {
+		panic(fmt.Errorf("Cassette call %d does not match script:\n%s", c.next, diffLines(call.Script, script)))
+	}
+	if recorded.Dir != dir {
+		panic(fmt.Errorf("Cassette call %d ran in %q, but script runs in %q:\n%s", c.next, recorded.Dir, dir, script))
+	}
+	recordedEnv, actualEnv := strings.Join(recorded.Env, "\n"), strings.Join(env, "\n")
+	if recordedEnv != actualEnv {
+		panic(fmt.Errorf("Cassette call %d does not match the environment of script:\n%s\n%s", c.next, script, diffLines(recordedEnv, actualEnv)))
+	}
+	c.next++
+	return &call
+}
+
+// Done returns an error if some calls in the cassette were not replayed.
+func (c *Cassette) Done() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.next < len(c.Calls) {
+		return fmt.Errorf("Cassette played %d of %d calls, next: %s", c.next, len(c.Calls), c.Calls[c.next].Script)
+	}
+	return nil
+}
+
+// diffLines shows the difference between two scripts line-by-line, with
+// expected lines prefixed by "-" and actual lines prefixed by "+".
+func diffLines(expected, actual string) string {
+	a := strings.Split(expected, "\n")
+	b := strings.Split(actual, "\n")
+	var out strings.Builder
+	for i := 0; i < len(a) || i < len(b); i++ {
+		switch {
+		case i >= len(a):
+			fmt.Fprintf(&out, "+ %s\n", b[i])
+		case i >= len(b):
+			fmt.Fprintf(&out, "- %s\n", a[i])
+		case a[i] == b[i]:
+			fmt.Fprintf(&out, "  %s\n", a[i])
+		default:
+			fmt.Fprintf(&out, "- %s\n+ %s\n", a[i], b[i])
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,151 @@
+package shell
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCassette(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cassette.json")
+
+	script := func(sh Interface) (string, bool) {
+		return sh.Outf("echo %s", "hello world"), sh.Succeeds("exit 3")
+	}
+
+	recording := &Cassette{}
+	out, ok := script(&Shell{Trace: recording})
+	if err := recording.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayedOut, replayedOk := script((&MockShell{}).Replay(cassette))
+	if replayedOut != out || replayedOk != ok {
+		t.Errorf("replay -> %q, %v != %q, %v", replayedOut, replayedOk, out, ok)
+	}
+	if err := cassette.Done(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCassetteMismatch(t *testing.T) {
+	defer func() {
+		err := recover().(error)
+		expected := "Cassette call 0 does not match script:\n  echo\n- one\n+ two\n"
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("%q does not contain %q", err.Error(), expected)
+		}
+	}()
+
+	cassette := &Cassette{Calls: []CassetteCall{{MockCall: MockCall{Script: "echo\none"}}}}
+	(&MockShell{}).Replay(cassette).Run("echo\ntwo")
+	t.Errorf("Run should panic")
+}
+
+func TestCassetteDirAndEnv(t *testing.T) {
+	recording := &Cassette{}
+	sh := &Shell{Trace: recording}
+	sh.WithDir("/").WithEnv(Vars{"A": 1}).Run(`true`)
+
+	cases := []struct {
+		sh  func(mock *MockShell) Interface
+		err string
+	}{
+		{func(mock *MockShell) Interface { return mock.WithDir("/").WithEnv(Vars{"A": 1}) }, ""},
+		{func(mock *MockShell) Interface { return mock.WithEnv(Vars{"A": 1}) }, `ran in "/", but script runs in ""`},
+		{func(mock *MockShell) Interface { return mock.WithDir("/").WithEnv(Vars{"A": 2}) }, "- A=1\n+ A=2"},
+	}
+	for _, c := range cases {
+		cassette := &Cassette{Calls: recording.Calls}
+		func() {
+			defer func() {
+				r := recover()
+				if c.err == "" && r != nil || c.err != "" && (r == nil || !strings.Contains(r.(error).Error(), c.err)) {
+					t.Errorf("replay -> panic %v, expected %q", r, c.err)
+				}
+			}()
+			c.sh((&MockShell{}).Replay(cassette)).Run(`true`)
+		}()
+	}
+}
+
+func TestCassettePipeline(t *testing.T) {
+	filtered := 0
+	upper := LineFilter(func(line string) (string, bool) {
+		filtered++
+		return strings.ToUpper(line), true
+	})
+	pipeline := func(sh Interface) string {
+		return sh.Pipe(`printf 'a\nb\n'`, upper, `tail -1`).Out()
+	}
+
+	recording := &Cassette{}
+	out := pipeline(&Shell{Trace: recording})
+	if out != "B" || filtered != 2 {
+		t.Fatalf("Pipe(...).Out() -> %q, filtered %d lines", out, filtered)
+	}
+
+	cassette := &Cassette{Calls: recording.Calls}
+	if replayed := pipeline((&MockShell{}).Replay(cassette)); replayed != out || filtered != 2 {
+		t.Errorf("replayed Pipe(...).Out() -> %q, filtered %d lines", replayed, filtered)
+	}
+	if err := cassette.Done(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCassetteLines(t *testing.T) {
+	lines := func(sh Interface) []string {
+		var got []string
+		sh.Lines(`printf 'a\nb\n'`, func(line string) error {
+			got = append(got, line)
+			return nil
+		})
+		return got
+	}
+
+	recording := &Cassette{}
+	got := lines(&Shell{Trace: recording})
+	cassette := &Cassette{Calls: recording.Calls}
+	if replayed := lines((&MockShell{}).Replay(cassette)); !reflect.DeepEqual(replayed, got) || len(got) != 2 {
+		t.Errorf("replayed Lines(...) -> %q != %q", replayed, got)
+	}
+}
+
+func TestCassetteRetries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	script := fmt.Sprintf(`test -e %[1]s || { touch %[1]s; exit 1; }; echo done`, filepath.Join(dir, "tried"))
+
+	recording := &Cassette{}
+	sh := (&Shell{Trace: recording}).With(Retries(Retry{Attempts: 2}))
+	if out := sh.Out(script); out != "done" {
+		t.Fatalf("Out(...) with Retries -> %q", out)
+	}
+	if len(recording.Calls) != 1 || recording.Calls[0].ExitStatus != 0 {
+		t.Fatalf("recorded %#v, expected only the last attempt", recording.Calls)
+	}
+	cassette := &Cassette{Calls: recording.Calls}
+	if out := (&MockShell{}).Replay(cassette).Out(script); out != "done" {
+		t.Errorf("replayed Out(...) -> %q", out)
+	}
+	if err := cassette.Done(); err != nil {
+		t.Error(err)
+	}
+}
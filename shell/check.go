@@ -0,0 +1,95 @@
+package shell
+
+// Checking scripts for syntax errors before they are run. See also the
+// shellvet analyzer, which checks the scripts in Go source code.
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// SyntaxError describes a syntax error in a script, and where it is.
+type SyntaxError struct {
+	// Position of the error in the script, starting from 1.
+	Line, Col int
+	Err       error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("script:%d:%d: %v", e.Line, e.Col, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// Check parses script as a Bash script without running it, and returns a
+// *SyntaxError if it is invalid.
+//
+//   if err := shell.Check(`if true; then echo ok`); err != nil {
+//     // script:1:1: if statement must end with "fi"
+//   }
+func Check(script string) error {
+	return checkLang(script, syntax.LangBash)
+}
+
+// Check checks script like the package-level Check, in the language of the
+// shell's Interpreter: POSIX sh for Sh, and Bash for other shells. Scripts for
+// an Interpreter with its own Quote function, like Python3, aren't shell
+// scripts, so they aren't checked, and Check returns nil.
+//
+//   py := sh.With(shell.Interp(shell.Python3))
+//   py.Check(`print(1)`) // nil
+func (sh *Shell) Check(script string) error {
+	interp := sh.interpreter()
+	switch {
+	case interp.Quote != nil:
+		return nil
+	case len(interp.Args) > 0 && filepath.Base(interp.Args[0]) == "sh":
+		return checkLang(script, syntax.LangPOSIX)
+	}
+	return checkLang(script, syntax.LangBash)
+}
+
+// checkLang parses script in lang without running it.
+func checkLang(script string, lang syntax.LangVariant) error {
+	parser := syntax.NewParser(syntax.Variant(lang))
+	_, err := parser.Parse(strings.NewReader(script), "")
+	if err == nil {
+		return nil
+	}
+
+	var pos syntax.Pos
+	switch e := err.(type) {
+	case syntax.ParseError:
+		pos = e.Pos
+	case syntax.LangError:
+		pos = e.Pos
+	default:
+		return err
+	}
+	msg := strings.TrimPrefix(err.Error(), pos.String()+": ")
+	return &SyntaxError{Line: int(pos.Line()), Col: int(pos.Col()), Err: errors.New(msg)}
+}
+
+// CheckFormat formats a script like ScriptPrintf, and checks it like Check. It
+// returns a *ContextError, instead of panicking, if a value can't be escaped
+// where it is in the script.
+//
+// Since escaped values never change the syntax of a script, the values only
+// need to have the right types. Eg. a stand-in "x" for a string, or 0 for an
+// int.
+//
+//   err := shell.CheckFormat(`echo $((%d + 1))`, 0)
+func CheckFormat(scriptformat string, vs ...interface{}) error {
+	script, err := formatScript(nil, nil, scriptformat, vs...)
+	if err != nil {
+		return err
+	}
+	return Check(script)
+}
@@ -0,0 +1,81 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	cases := []struct {
+		script string
+		err    string
+	}{
+		{`echo ok`, ""},
+		{"for f in *.go; do\n  gofmt -l \"$f\"\ndone", ""},
+		{`declare -A m; m[a]=1; [[ -n ${m[a]} ]]`, ""},
+		{`if true; then echo ok`, `script:1:1: if statement must end with "fi"`},
+		{`echo 'unclosed`, `script:1:6: reached EOF without closing quote '`},
+		{"echo ok\necho $((1 +", `script:2:11: + must be followed by an expression`},
+	}
+
+	for _, c := range cases {
+		err := Check(c.script)
+		if c.err == "" && err != nil {
+			t.Errorf("Check(%q) -> %v", c.script, err)
+		}
+		if c.err != "" && (err == nil || err.Error() != c.err) {
+			t.Errorf("Check(%q) -> %v != %s", c.script, err, c.err)
+		}
+	}
+}
+
+func TestCheckFormat(t *testing.T) {
+	cases := []struct {
+		format string
+		v      interface{}
+		err    string
+	}{
+		{`echo %s`, "x", ""},
+		{`echo '%s'; echo $((%d + 1))`, "x", ""},
+		{"echo `%s`", Raw("x"), ""},
+		{"echo `%s`", "x", "inside backticks"},
+		{`echo $((%s + 1))`, "x", "in arithmetic"},
+		{`echo %s; fi`, "x", "script:1:9:"},
+	}
+
+	for _, c := range cases {
+		vs := []interface{}{c.v}
+		if strings.Contains(c.format, "%d") {
+			vs = append(vs, 0)
+		}
+		err := CheckFormat(c.format, vs...)
+		if c.err == "" && err != nil {
+			t.Errorf("CheckFormat(%q) -> %v", c.format, err)
+		}
+		if c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)) {
+			t.Errorf("CheckFormat(%q) -> %v, should contain %q", c.format, err, c.err)
+		}
+	}
+}
+
+func TestShellCheck(t *testing.T) {
+	cases := []struct {
+		interp *Interpreter
+		script string
+		valid  bool
+	}{
+		{nil, `[[ -n $x ]]`, true},
+		{Bash, `print(1)`, false},
+		{Sh, `a=(1 2)`, false},
+		{Sh, `[ -n "$x" ]`, true},
+		{Python3, `print(1)`, true},
+		{Python3, `if true; then`, true},
+	}
+
+	for _, c := range cases {
+		sh := &Shell{Interpreter: c.interp}
+		if err := sh.Check(c.script); (err == nil) != c.valid {
+			t.Errorf("Interpreter %v: Check(%q) -> %v", c.interp, c.script, err)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package shell
+
+// Ways to decode the output of a script into Go values.
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DecodeError describes output of a script that could not be decoded.
+type DecodeError struct {
+	// The script that printed the output.
+	Script string
+	// The format the output should have been in, like "JSON" or "CSV".
+	Format string
+	// The part of the output near the problem.
+	Snippet string
+	// The error from the decoder.
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("Could not decode output of %q as %s: %v, near %q", e.Script, e.Format, e.Err, e.Snippet)
+}
+
+// Unwrap returns the error from the decoder.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Characters of output on each side of a problem in a DecodeError snippet.
+const snippetRadius = 32
+
+// decodeError creates a DecodeError for output that is bad at offset.
+func decodeError(script, format, output string, offset int, err error) *DecodeError {
+	start, end := offset-snippetRadius, offset+snippetRadius
+	prefix, suffix := "...", "..."
+	if start <= 0 {
+		start, prefix = 0, ""
+	}
+	if end >= len(output) {
+		end, suffix = len(output), ""
+	}
+	if start > end {
+		start = end
+	}
+	return &DecodeError{script, format, prefix + output[start:end] + suffix, err}
+}
+
+// JSON decodes Stdout as JSON into v, like json.Unmarshal. The error is a
+// *DecodeError.
+func (r *Result) JSON(v interface{}) error {
+	err := json.Unmarshal([]byte(r.Stdout), v)
+	if err == nil {
+		return nil
+	}
+	offset := 0
+	switch err := err.(type) {
+	case *json.SyntaxError:
+		offset = int(err.Offset)
+	case *json.UnmarshalTypeError:
+		offset = int(err.Offset)
+	}
+	return decodeError(r.Script, "JSON", r.Stdout, offset, err)
+}
+
+// Fields splits each line of Stdout into fields separated by sep. If sep is
+// empty, fields are separated by whitespace, like strings.Fields.
+func (r *Result) Fields(sep string) [][]string {
+	lines := r.Lines()
+	fields := make([][]string, len(lines))
+	for i, line := range lines {
+		if sep == "" {
+			fields[i] = strings.Fields(line)
+		} else {
+			fields[i] = strings.Split(line, sep)
+		}
+	}
+	return fields
+}
+
+// CSV decodes Stdout as comma-separated values, like csv.Reader.ReadAll. The
+// error is a *DecodeError.
+func (r *Result) CSV() ([][]string, error) {
+	records, err := csv.NewReader(strings.NewReader(r.Stdout)).ReadAll()
+	if err == nil {
+		return records, nil
+	}
+	offset := 0
+	if perr, ok := err.(*csv.ParseError); ok {
+		for line := 1; line < perr.Line && offset < len(r.Stdout); line++ {
+			offset += strings.IndexByte(r.Stdout[offset:], '\n') + 1
+		}
+	}
+	return nil, decodeError(r.Script, "CSV", r.Stdout, offset, err)
+}
+
+// OutJSON decodes the Stdout of a script as JSON into v. If the script exits
+// non-zero, its error is returned like OutStatus. If the output is not valid
+// JSON, a *DecodeError is returned.
+//
+//   var pods struct{ Items []Pod }
+//   err := sh.OutJSON(`kubectl get pods -o json`, &pods)
+//
+// @StaticCompose.Inside("formatters")
+func (sh *Shell) OutJSON(script string, v interface{}) error {
+	res := sh.outResult(script)
+	return sh.decoded(res, func() error { return res.JSON(v) })
+}
+
+// OutLines returns the lines of Stdout of a script, without their newlines.
+// Like Out, errors are printed to the default Stderr.
+//
+//   for _, branch := range sh.OutLines(`git branch --format='%(refname:short)'`) {
+//
+// @StaticCompose.Inside("formatters")
+func (sh *Shell) OutLines(script string) []string {
+	return sh.outResult(script).Lines()
+}
+
+// OutFields splits each line of Stdout of a script into fields separated by
+// sep, or by whitespace if sep is empty. Like Out, errors are printed to the
+// default Stderr.
+//
+//   for _, f := range sh.OutFields(`getent passwd`, ":") {
+//     fmt.Println(f[0], f[5])
+//   }
+//
+// @StaticCompose.Inside("formatters")
+func (sh *Shell) OutFields(script string, sep string) [][]string {
+	return sh.outResult(script).Fields(sep)
+}
+
+// OutCSV decodes the Stdout of a script as comma-separated values. If the
+// script exits non-zero, its error is returned like OutStatus. If the output
+// is not valid CSV, a *DecodeError is returned.
+//
+// @StaticCompose.Inside("formatters")
+func (sh *Shell) OutCSV(script string) ([][]string, error) {
+	res := sh.outResult(script)
+	var records [][]string
+	err := sh.decoded(res, func() (err error) {
+		records, err = res.CSV()
+		return err
+	})
+	return records, err
+}
+
+// outResult runs script like Out, and returns its Result.
+func (sh *Shell) outResult(script string) *Result {
+	return sh.do(sh.scriptCmd(script), script, nil, sh.Stderr)
+}
+
+// decoded returns the error of res if it failed, or else the error of decode,
+// which is handled like an exit status.
+func (sh *Shell) decoded(res *Result, decode func() error) error {
+	if err := res.Err(); err != nil {
+		return err
+	}
+	err := decode()
+	if err != nil {
+		sh.onError(err)
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package shell
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOutJSON(t *testing.T) {
+	sh := &Shell{}
+	var v struct{ Name string }
+	if err := sh.OutJSONf(&v, `echo %s`, `{"Name": "api"}`); err != nil || v.Name != "api" {
+		t.Errorf("OutJSONf(...) -> %v, %#v", err, v)
+	}
+
+	err := sh.OutJSON(`echo '{"Name": oops}'`, &v)
+	var derr *DecodeError
+	var serr *json.SyntaxError
+	if !errors.As(err, &derr) || !errors.As(err, &serr) {
+		t.Fatalf("OutJSON(...) -> %#v, expected a *DecodeError wrapping a *json.SyntaxError", err)
+	}
+	if derr.Script != `echo '{"Name": oops}'` || !strings.Contains(derr.Snippet, "oops") {
+		t.Errorf("DecodeError -> %#v, expected script and snippet", derr)
+	}
+
+	if err := sh.OutJSON(`exit 2`, &v); err == nil || errors.As(err, &derr) {
+		t.Errorf("OutJSON(`exit 2`) -> %v, expected the exit status", err)
+	}
+}
+
+func TestOutLinesFields(t *testing.T) {
+	sh := &Shell{}
+	if lines := sh.OutLines(`printf 'a\nb\n'`); !reflect.DeepEqual(lines, []string{"a", "b"}) {
+		t.Errorf("OutLines(...) -> %q", lines)
+	}
+	cases := []struct {
+		sep      string
+		expected [][]string
+	}{
+		{"", [][]string{{"a", "b"}, {"c:d"}}},
+		{":", [][]string{{"a  b"}, {"c", "d"}}},
+	}
+	for _, c := range cases {
+		if fields := sh.OutFields(`printf 'a  b\nc:d\n'`, c.sep); !reflect.DeepEqual(fields, c.expected) {
+			t.Errorf("OutFields(..., %q) -> %q != %q", c.sep, fields, c.expected)
+		}
+	}
+}
+
+func TestOutCSV(t *testing.T) {
+	sh := &MockShell{}
+	sh.AddMock(MockCall{Script: "good", Stdout: "a,b\n\"c,d\",e"})
+	sh.AddMock(MockCall{Script: "bad", Stdout: "a,b\nc,\"d\n"})
+
+	records, err := sh.OutCSV("good")
+	if err != nil || !reflect.DeepEqual(records, [][]string{{"a", "b"}, {"c,d", "e"}}) {
+		t.Errorf("OutCSV(`good`) -> %q, %v", records, err)
+	}
+	_, err = sh.OutCSV("bad")
+	if derr, ok := err.(*DecodeError); !ok || !strings.Contains(derr.Snippet, `c,"d`) {
+		t.Errorf("OutCSV(`bad`) -> %#v, expected snippet of line 2", err)
+	}
+}
@@ -0,0 +1,82 @@
+package shell
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"sync"
+)
+
+// DryRun configures a Shell to print scripts instead of running them. Set
+// Shell.DryRun to enable it for every way of running a script, except for
+// commands returned by Cmd, which are never touched by the Shell after they are
+// returned.
+//
+//   sh.DryRun = NewDryRun(`^git (rev-parse|status)\b`, `^kubectl get\b`)
+//   sh.Must().Runf(`kubectl apply -f %s`, manifest) // only printed
+type DryRun struct {
+	// Scripts are printed here before they would run, prefixed with "+ ". If
+	// nil, os.Stderr is used.
+	Out io.Writer
+	// Scripts matching any of these patterns are considered read-only, and
+	// will really run.
+	Allow []*regexp.Regexp
+	// If set, returns the fake result of a script that didn't run. The
+	// MockCall's Script is ignored. By default, scripts succeed with no output.
+	Results func(script string) MockCall
+	// Stages of a Pipeline may print concurrently.
+	mu sync.Mutex
+}
+
+// NewDryRun returns a DryRun that really runs scripts matching any of the
+// allow patterns. It panics if a pattern is not a valid regexp.
+func NewDryRun(allow ...string) *DryRun {
+	d := &DryRun{}
+	for _, pattern := range allow {
+		d.Allow = append(d.Allow, regexp.MustCompile(pattern))
+	}
+	return d
+}
+
+// Allowed returns true if the script should really run.
+func (d *DryRun) Allowed(script string) bool {
+	for _, pattern := range d.Allow {
+		if pattern.MatchString(script) {
+			return true
+		}
+	}
+	return false
+}
+
+// fake prints the script, with secrets masked by mask, then writes the fake
+// output to cmd's Stdout and Stderr instead of running it. Returns the fake
+// exit status, as an *ExitError made without starting a process.
+func (d *DryRun) fake(cmd *exec.Cmd, script string, mask func(s string) string) error {
+	out := d.Out
+	if out == nil {
+		out = os.Stderr
+	}
+	d.mu.Lock()
+	printXTrace(out, mask(script))
+	d.mu.Unlock()
+
+	call := MockCall{}
+	if d.Results != nil {
+		call = d.Results(script)
+	}
+	if call.Script == "" {
+		call.Script = script
+	}
+	call.write(cmd.Stdout, cmd.Stderr)
+	// Avoid returning a nil *ExitError as a non-nil error.
+	if err := call.ExitError(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// dryRun returns true if script should not really run.
+func (sh *Shell) dryRun(script string) bool {
+	return sh.DryRun != nil && !sh.DryRun.Allowed(script)
+}
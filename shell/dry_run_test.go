@@ -0,0 +1,49 @@
+package shell
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	var printed bytes.Buffer
+	sh := &Shell{DryRun: NewDryRun(`^echo\b`)}
+	sh.DryRun.Out = &printed
+	sh.DryRun.Results = func(script string) MockCall {
+		if script == "cat /etc/hostname" {
+			return MockCall{Stdout: "example"}
+		}
+		return MockCall{ExitStatus: 2}
+	}
+
+	if out := sh.Out(`echo really`); out != "really" {
+		t.Errorf("allowed script should run, got %q", out)
+	}
+	if out := sh.Outp(Raw("cat "), "/etc/hostname"); out != "example" {
+		t.Errorf("Outp -> %q != %q", out, "example")
+	}
+	if sh.Succeeds(`rm -rf /`) || sh.LastError().ExitCode() != 2 {
+		t.Errorf("Succeeds should use the fake exit status")
+	}
+	if sh.LastError().Unwrap() != nil {
+		t.Errorf("a dry run should not start a process, got %v", sh.LastError().Unwrap())
+	}
+	var execErr *exec.ExitError
+	if !errors.As(sh.LastError(), &execErr) || execErr.ExitCode() != 2 {
+		t.Errorf("errors.As(LastError(), *exec.ExitError) -> %v", execErr)
+	}
+	upper := LineFilter(func(line string) (string, bool) {
+		return strings.ToUpper(line), true
+	})
+	if out := sh.Pipe(`cat /etc/hostname`, upper).Out(); out != "EXAMPLE" {
+		t.Errorf("Pipe -> %q != %q", out, "EXAMPLE")
+	}
+
+	expected := "+ cat /etc/hostname\n+ rm -rf /\n+ cat /etc/hostname\n"
+	if printed.String() != expected {
+		t.Errorf("printed %q != %q", printed.String(), expected)
+	}
+}
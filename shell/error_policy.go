@@ -0,0 +1,188 @@
+package shell
+
+// What happens when a script fails.
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// ErrorPolicy decides what happens when running a script fails. A policy may
+// panic, exit the program, or record the error. If HandleError returns, the
+// error is returned to the caller as usual, for methods that return errors.
+//
+// HandleError is called with an *ExitError when a script exits non-zero, and
+// with errors like *FilterError, *DecodeError and *ParallelError when
+// scripts run but fail in other ways; see IsFailure. Other errors mean a
+// script could not be run at all, for example because bash was not found.
+type ErrorPolicy interface {
+	HandleError(err error)
+}
+
+// ErrorPolicyFunc adapts a function to an ErrorPolicy, for example to call a
+// handler for each error.
+//
+//   sh.ErrorPolicy = shell.ErrorPolicyFunc(func(err error) {
+//     metrics.Increment("script.failures")
+//   })
+type ErrorPolicyFunc func(err error)
+
+// HandleError calls fn(err).
+func (fn ErrorPolicyFunc) HandleError(err error) {
+	fn(err)
+}
+
+var (
+	// DefaultErrorPolicy returns failures to the caller, and panics with other
+	// errors. It is used by shells without an ErrorPolicy.
+	DefaultErrorPolicy ErrorPolicy = ErrorPolicyFunc(func(err error) {
+		if !IsFailure(err) {
+			panic(err)
+		}
+	})
+	// PanicOnError panics with every error. It is the policy of the Must
+	// option.
+	PanicOnError ErrorPolicy = ErrorPolicyFunc(func(err error) {
+		panic(err)
+	})
+	// ReturnErrors returns every error to the caller, and never panics.
+	ReturnErrors ErrorPolicy = ErrorPolicyFunc(func(err error) {})
+)
+
+// IsFailure returns true if err describes scripts that ran and failed, like an
+// *ExitError, rather than an error that prevented a script from running.
+// Errors that wrap one of these, like with fmt.Errorf's %w, are failures too.
+func IsFailure(err error) bool {
+	var (
+		exitErr     *ExitError
+		filterErr   *FilterError
+		decodeErr   *DecodeError
+		parallelErr *ParallelError
+	)
+	return errors.As(err, &exitErr) || errors.As(err, &filterErr) ||
+		errors.As(err, &decodeErr) || errors.As(err, &parallelErr)
+}
+
+// LogErrors returns a policy that logs every error to logger, or the standard
+// logger if nil, and continues.
+func LogErrors(logger *log.Logger) ErrorPolicy {
+	return ErrorPolicyFunc(func(err error) {
+		logError(logger, err)
+	})
+}
+
+// ExitOnError returns a policy that logs an error to logger, or the standard
+// logger if nil, and exits the program like log.Fatal. Unlike log.Fatal, the
+// program exits with the failed script's exit status, so a CLI tool built on
+// a Shell fails the same way as the script it ran:
+//
+//   func main() {
+//     sh := &shell.Shell{ErrorPolicy: shell.ExitOnError(nil)}
+//     sh.Run(`make release`) // exits 2 if make exits 2
+//   }
+func ExitOnError(logger *log.Logger) ErrorPolicy {
+	return ErrorPolicyFunc(func(err error) {
+		logError(logger, err)
+		exit(ExitStatus(err))
+	})
+}
+
+// exit is os.Exit, replaced in tests.
+var exit = os.Exit
+
+func logError(logger *log.Logger, err error) {
+	if logger == nil {
+		log.Println(err)
+		return
+	}
+	logger.Println(err)
+}
+
+// ExitStatus returns the exit status a shell would report for err: 0 for nil,
+// the exit code of an *ExitError, 128 plus the signal number for a script
+// killed by a signal, 127 for a script that could not be run, and 1 for other
+// failures. Wrapped errors are unwrapped to find an *ExitError.
+func ExitStatus(err error) int {
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) {
+		if err == nil {
+			return 0
+		}
+		if IsFailure(err) {
+			return 1
+		}
+		return 127
+	}
+	if sig, ok := exitErr.Signal.(syscall.Signal); ok {
+		return 128 + int(sig)
+	}
+	return exitErr.ExitCode()
+}
+
+// ErrorList is an ErrorPolicy that collects every error, and continues. It is
+// safe to share between goroutines.
+//
+//   errs := &shell.ErrorList{}
+//   sh := (&shell.Shell{ErrorPolicy: errs})
+//   for _, host := range hosts {
+//     sh.Runf(`ssh %s uptime`, host)
+//   }
+//   if err := errs.Err(); err != nil {
+//     log.Fatal(err)
+//   }
+type ErrorList struct {
+	mu     sync.Mutex
+	errors []error
+}
+
+// HandleError adds err to the list.
+func (l *ErrorList) HandleError(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errors = append(l.errors, err)
+}
+
+// Errors returns the collected errors, in the order they happened.
+func (l *ErrorList) Errors() []error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]error(nil), l.errors...)
+}
+
+// Err returns nil if no errors were collected, or else an error listing every
+// collected error.
+func (l *ErrorList) Err() error {
+	errs := l.Errors()
+	if len(errs) == 0 {
+		return nil
+	}
+	return errorList(errs)
+}
+
+type errorList []error
+
+func (errs errorList) Error() string {
+	msg := fmt.Sprintf("%d errors:", len(errs))
+	for _, err := range errs {
+		msg += "\n" + err.Error()
+	}
+	return msg
+}
+
+// printUnexpected is the policy of a shell with IgnoreUnexpectedErrors: errors
+// that prevented a script from running are printed to w, or os.Stderr if nil.
+func printUnexpected(w io.Writer) ErrorPolicy {
+	if w == nil {
+		w = os.Stderr
+	}
+	return ErrorPolicyFunc(func(err error) {
+		if !IsFailure(err) {
+			fmt.Fprintln(w, err)
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package shell
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestErrorPolicies(t *testing.T) {
+	missing := []string{"/does/not/exist", "-c"}
+
+	errs := &ErrorList{}
+	sh := &Shell{ErrorPolicy: errs}
+	sh.Run(`exit 1`)
+	(&Shell{DefaultArgs: missing, ErrorPolicy: errs}).Run(`true`)
+	sh.Run(`true`)
+	if n := len(errs.Errors()); n != 2 || errs.Err() == nil {
+		t.Errorf("ErrorList collected %d errors, expected 2", n)
+	}
+
+	var logged bytes.Buffer
+	sh = &Shell{DefaultArgs: missing, ErrorPolicy: LogErrors(log.New(&logged, "", 0))}
+	if err := sh.Run(`true`); err == nil || !strings.Contains(logged.String(), "/does/not/exist") {
+		t.Errorf("LogErrors logged %q, and Run returned %v", logged.String(), err)
+	}
+
+	handled := 0
+	sh = &Shell{ErrorPolicy: ErrorPolicyFunc(func(err error) { handled++ })}
+	sh.Run(`exit 1`)
+	sh.Pipe(`echo`, Filter(func(in io.Reader, out io.Writer) error { return errors.New("no") })).Run()
+	if handled != 2 {
+		t.Errorf("ErrorPolicyFunc called %d times, expected 2", handled)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("DefaultErrorPolicy should panic if bash can't be found")
+		}
+	}()
+	(&Shell{DefaultArgs: missing}).Run(`true`)
+}
+
+func TestExitOnError(t *testing.T) {
+	defer func(original func(int)) { exit = original }(exit)
+	cases := []struct {
+		script string
+		status int
+	}{
+		{`exit 3`, 3},
+		{`kill -TERM $$`, 143},
+	}
+	for _, c := range cases {
+		status := -1
+		exit = func(code int) { status = code }
+		var logged bytes.Buffer
+		sh := &Shell{ErrorPolicy: ExitOnError(log.New(&logged, "", 0))}
+		sh.Run(c.script)
+		if status != c.status || !strings.Contains(logged.String(), c.script) {
+			t.Errorf("ExitOnError for %q exited %d, logged %q", c.script, status, logged.String())
+		}
+	}
+}
+
+func TestWrappedErrors(t *testing.T) {
+	exitErr := (&Shell{}).Run(`exit 3`)
+	mock := &MockShell{}
+	mock.AddMock(MockCall{Script: "make", ExitStatus: 2, Stderr: "no rule"})
+	mockErr := mock.Run(`make`)
+	cases := []struct {
+		err     error
+		failure bool
+		status  int
+	}{
+		{exitErr, true, 3},
+		{fmt.Errorf("deploy: %w", exitErr), true, 3},
+		{mockErr, true, 2},
+		{fmt.Errorf("deploy: %w", mockErr), true, 2},
+		{fmt.Errorf("deploy: %w", &FilterError{Err: errors.New("no")}), true, 1},
+		{fmt.Errorf("deploy: %w", errors.New("not found")), false, 127},
+	}
+	for _, c := range cases {
+		if IsFailure(c.err) != c.failure || ExitStatus(c.err) != c.status {
+			t.Errorf("%v: IsFailure -> %v, ExitStatus -> %d", c.err, IsFailure(c.err), ExitStatus(c.err))
+		}
+		var execErr *exec.ExitError
+		if c.failure && c.status > 1 && (!errors.As(c.err, &execErr) || execErr.ExitCode() != c.status) {
+			t.Errorf("%v: errors.As(err, *exec.ExitError) -> %v", c.err, execErr)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package shell
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// ExitError describes a script that exited non-zero. If the script ran as a
+// process, ExitError wraps its *exec.ExitError, so errors.As can still find
+// it. Scripts that ran in-process, and mocked scripts, have no process, but
+// errors.As finds an *exec.ExitError with the same exit status for them too.
+//
+//   if err := sh.Run(`make test`); err != nil {
+//     var exitErr *shell.ExitError
+//     if errors.As(err, &exitErr) && exitErr.ExitCode() == 2 {
+//       fmt.Println(exitErr.StderrTail)
+//     }
+//   }
+type ExitError struct {
+	// The script that failed.
+	Script string
+	// The directory the script ran in, or "" for the current directory.
+	Dir string
+	// Exit code of the script, or -1 if it was killed by a signal.
+	Code int
+	// The signal that killed the script, if any.
+	Signal os.Signal
+	// The last few lines the script wrote to Stderr.
+	StderrTail string
+
+	// The error from the process, if the script ran as one.
+	err *exec.ExitError
+}
+
+// ExitCode returns the exit code of the script, or -1 if it was killed by a
+// signal.
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+// Error describes the script, how it exited, and the tail of its Stderr.
+func (e *ExitError) Error() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Script %q failed with %s", e.Script, e.status())
+	if e.Dir != "" {
+		fmt.Fprintf(&buf, " in %s", e.Dir)
+	}
+	for _, line := range strings.Split(e.StderrTail, "\n") {
+		if line != "" {
+			fmt.Fprintf(&buf, "\n    %s", line)
+		}
+	}
+	return buf.String()
+}
+
+// status describes how the script exited, like "exit status 2".
+func (e *ExitError) status() string {
+	if e.Signal != nil {
+		return "signal: " + e.Signal.String()
+	}
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// Unwrap returns the *exec.ExitError of the process, or nil if the script
+// didn't run as a process. See As.
+func (e *ExitError) Unwrap() error {
+	if e.err == nil {
+		return nil
+	}
+	return e.err
+}
+
+// As lets errors.As find an *exec.ExitError for a script that didn't run as a
+// process, so code written for os/exec keeps working with mocked and
+// in-process scripts. Since an *os.ProcessState can't be made directly, the
+// exit status is reproduced by running sh, but only when it is asked for. As
+// returns false for exit statuses a process can't exit with.
+func (e *ExitError) As(target interface{}) bool {
+	execErr, ok := target.(**exec.ExitError)
+	if !ok || e.err != nil {
+		return false
+	}
+	script := fmt.Sprintf("exit %d", e.Code)
+	if sig, ok := e.Signal.(syscall.Signal); ok {
+		script = fmt.Sprintf("kill -%d $$", int(sig))
+	} else if e.Code < 1 || e.Code > 255 {
+		return false
+	}
+	err, ok := exec.Command("sh", "-c", script).Run().(*exec.ExitError)
+	if !ok {
+		return false
+	}
+	err.Stderr = []byte(e.StderrTail)
+	*execErr = err
+	return true
+}
+
+// wrapExitError returns err as an *ExitError if it is an *exec.ExitError from
+// running script, or an *ExitError without the script's context. Otherwise it
+// returns err unchanged.
+func wrapExitError(err error, script, dir, stderr string) error {
+	switch e := err.(type) {
+	case *exec.ExitError:
+		return &ExitError{
+			Script:     script,
+			Dir:        dir,
+			Code:       e.ExitCode(),
+			Signal:     exitSignal(e.ProcessState),
+			StderrTail: lastLines(stderr, stderrTailLines),
+			err:        e,
+		}
+	case *ExitError:
+		if e.Script != "" {
+			return err
+		}
+		wrapped := *e
+		wrapped.Script = script
+		wrapped.Dir = dir
+		wrapped.StderrTail = lastLines(stderr, stderrTailLines)
+		return &wrapped
+	}
+	return err
+}
+
+// exitSignal returns the signal that killed a process, or nil.
+func exitSignal(state *os.ProcessState) os.Signal {
+	if state == nil {
+		return nil
+	}
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return status.Signal()
+	}
+	return nil
+}
+
+// briefError describes err without the script context of an ExitError, for
+// places that already show the script.
+func briefError(err error) string {
+	if exitErr, ok := err.(*ExitError); ok {
+		return exitErr.status()
+	}
+	return err.Error()
+}
+
+// tailWriter remembers the last bytes written to it.
+type tailWriter struct {
+	buf []byte
+}
+
+// Bytes of Stderr kept by a tailWriter.
+const tailBytes = 4096
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	if len(w.buf) > tailBytes {
+		w.buf = w.buf[len(w.buf)-tailBytes:]
+	}
+	return len(p), nil
+}
+
+func (w *tailWriter) String() string {
+	return string(w.buf)
+}
@@ -0,0 +1,50 @@
+package shell
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestExitError(t *testing.T) {
+	var stderr strings.Builder
+	sh := &Shell{Dir: os.TempDir(), Stderr: &stderr}
+	_, err := sh.OutStatus(`echo first >&2; echo why >&2; exit 3`)
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("OutStatus(...) -> %#v, expected an *ExitError", err)
+	}
+	if exitErr.ExitCode() != 3 || exitErr.Dir != os.TempDir() || exitErr.StderrTail != "first\nwhy" {
+		t.Errorf("ExitError -> %#v", exitErr)
+	}
+	msg := exitErr.Error()
+	for _, s := range []string{`"echo first >&2; echo why >&2; exit 3"`, "exit status 3", "    why"} {
+		if !strings.Contains(msg, s) {
+			t.Errorf("ExitError.Error() -> %q does not contain %q", msg, s)
+		}
+	}
+	var execErr *exec.ExitError
+	if !errors.As(err, &execErr) || execErr.ExitCode() != 3 {
+		t.Errorf("errors.As(err, *exec.ExitError) -> %v", execErr)
+	}
+	if sh.LastError() != exitErr {
+		t.Errorf("LastError() -> %v, expected %v", sh.LastError(), exitErr)
+	}
+
+	defer func() {
+		if r, ok := recover().(*ExitError); !ok || r.Script != `kill -9 $$` || r.Signal == nil {
+			t.Errorf("Must().Run(...) panicked with %#v, expected a killed *ExitError", r)
+		}
+	}()
+	sh.Must().Run(`kill -9 $$`)
+}
+
+func TestMockCallExitErrorContext(t *testing.T) {
+	err := MockCall{Script: "make", ExitStatus: 2, Stderr: "no rule"}.ExitError()
+	if err.Script != "make" || err.ExitCode() != 2 || err.StderrTail != "no rule" {
+		t.Errorf("MockCall.ExitError() -> %#v", err)
+	}
+}
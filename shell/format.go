@@ -4,9 +4,10 @@ package shell
 
 import (
 	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
-
-	shellquote "github.com/kballard/go-shellquote"
 )
 
 // Raw strings will not be automatically escaped when interpolated into shell
@@ -17,6 +18,115 @@ func (r Raw) GoString() string {
 	return fmt.Sprintf("shell.Raw(%#v)", string(r))
 }
 
+// Secret strings are escaped like any other string when interpolated into
+// shell scripts, but are masked in the trace output of the Shell whose
+// methods escaped them. See Shell.Trace and Shell.MaskSecret.
+type Secret string
+
+func (s Secret) GoString() string {
+	return "shell.Secret(***)"
+}
+
+// Escaper is implemented by values that escape themselves when they are
+// interpolated into a script. The result is used as it is, like a Raw value.
+type Escaper interface {
+	Escape() Raw
+}
+
+// Arger is implemented by values that stand for several words of a script,
+// or several arguments of a command, like a slice does. Each word is escaped
+// separately.
+type Arger interface {
+	Args() []string
+}
+
+// MapArgs is an Arger that expands a map into a word for each key, sorted by
+// key, like "--key=value". Maps that are not wrapped in a MapArgs expand to
+// "key=value" words.
+//
+//   sh.Runp("docker", "run", shell.Flags(map[string]interface{}{"rm": true, "name": name}), image)
+type MapArgs struct {
+	// A map with any type of keys and values.
+	Map interface{}
+	// Put before each key, eg. "--".
+	Prefix string
+	// Put between each key and its value, eg. "=". If Sep is " ", the key and
+	// value are separate words.
+	Sep string
+}
+
+// Flags expands a map into "--key=value" words. A key whose value is true
+// becomes "--key", and a key whose value is false is left out.
+func Flags(m interface{}) MapArgs {
+	return MapArgs{Map: m, Prefix: "--", Sep: "="}
+}
+
+// Args implements Arger for MapArgs. It panics if Map is not a map.
+func (m MapArgs) Args() []string {
+	v := reflect.ValueOf(m.Map)
+	if v.Kind() != reflect.Map {
+		panic(fmt.Errorf("MapArgs: %T is not a map", m.Map))
+	}
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+	})
+
+	var args []string
+	for _, key := range keys {
+		name := m.Prefix + fmt.Sprint(key)
+		val := v.MapIndex(key).Interface()
+		if flag, ok := val.(bool); ok && m.Prefix != "" {
+			if flag {
+				args = append(args, name)
+			}
+			continue
+		}
+		value := string(ToRaw(val))
+		if m.Sep == " " {
+			args = append(args, name, value)
+		} else {
+			args = append(args, name+m.Sep+value)
+		}
+	}
+	return args
+}
+
+// expand returns the words that val stands for, if it is a slice, array,
+// map, or Arger. Slices of bytes and Stringers are not expanded.
+func expand(val interface{}) ([]interface{}, bool) {
+	var args []string
+	switch v := val.(type) {
+	case Arger:
+		args = v.Args()
+	case fmt.Stringer:
+		return nil, false
+	default:
+		list := reflect.ValueOf(val)
+		switch list.Kind() {
+		case reflect.Slice, reflect.Array:
+			if list.Type().Elem().Kind() == reflect.Uint8 {
+				// Bytes are a string, not a list.
+				return nil, false
+			}
+			words := make([]interface{}, list.Len())
+			for i := range words {
+				words[i] = list.Index(i).Interface()
+			}
+			return words, true
+		case reflect.Map:
+			args = MapArgs{Map: val, Sep: "="}.Args()
+		default:
+			return nil, false
+		}
+	}
+	words := make([]interface{}, len(args))
+	for i, arg := range args {
+		words[i] = arg
+	}
+	return words, true
+}
+
 // ToRaw coerces any value into an unescaped string for the purposes of
 // shell command construction using fmt.Sprint.
 func ToRaw(v interface{}) Raw {
@@ -24,16 +134,75 @@ func ToRaw(v interface{}) Raw {
 	return Raw(s)
 }
 
-// Escape a value.
+// Escape a value as a single word, using the quoting rules of Bash and other
+// POSIX shells. Use Shell.Escape to quote values for the shell's Interpreter
+// instead. Unlike ScriptPrintf, Escape doesn't know where the value will be
+// used, so it is only safe outside of quotes.
+//
+// Slices, maps and Argers become several words, each escaped separately.
+// Escapers escape themselves.
+//
+//   Escape([]string{"a b", "c"}) // -> 'a b' c
 func Escape(val interface{}) Raw {
+	return escape(nil, nil, val)
+}
+
+// Escape a value, using the quoting rules of the shell's Interpreter. Secret
+// values are masked in the shell's traces.
+//
+//   py := sh.With(shell.Interp(shell.Python3))
+//   py.Escape("it's") // -> "it's", as a Python string literal
+func (sh *Shell) Escape(val interface{}) Raw {
+	return escape(sh.quote(), sh.secrets(), val)
+}
+
+// escape escapes val with quote, and adds any Secret values to secrets. Lists
+// are escaped as words for a POSIX shell, or as a list literal if quote is
+// some other language's.
+func escape(quote func(s string) string, secrets *secretSet, val interface{}) Raw {
+	list := func(items []string) Raw {
+		return Raw("[" + strings.Join(items, ", ") + "]")
+	}
+	if quote == nil {
+		quote = quotePOSIX
+		list = func(words []string) Raw {
+			return Raw(strings.Join(words, " "))
+		}
+	}
 	switch v := val.(type) {
 	case Raw:
 		return v
+	case Escaper:
+		return v.Escape()
+	case Secret:
+		escaped := quote(string(v))
+		secrets.add(string(v), escaped)
+		return Raw(escaped)
 	case string:
-		return Raw(shellquote.Join(v))
-	default:
-		return Raw(shellquote.Join(fmt.Sprint(v)))
+		return Raw(quote(v))
+	case []byte:
+		return Raw(quote(string(v)))
 	}
+	if words, ok := expand(val); ok {
+		escaped := make([]string, len(words))
+		for i, word := range words {
+			escaped[i] = string(escape(quote, secrets, word))
+		}
+		return list(escaped)
+	}
+	return Raw(quote(fmt.Sprint(val)))
+}
+
+// needsSpace returns true if ScriptPrint should put a space between a and b.
+func needsSpace(a, b interface{}) bool {
+	_, aRaw := a.(Raw)
+	_, bRaw := b.(Raw)
+	_, aList := expand(a)
+	_, bList := expand(b)
+	if aList && !bRaw || bList && !aRaw {
+		return true
+	}
+	return !stringly(a) && !stringly(b)
 }
 
 func stringly(v interface{}) bool {
@@ -60,16 +229,32 @@ func stringly(v interface{}) bool {
 // but: any non-Raw values will be escaped first
 //   ScriptPrint(Raw(`cat `), filename, Raw(` | grep -v `, regexp, ` tee log`))
 //
-// @StaticCompose.Group("formatters", "%sp")
+// Slices, maps and Argers expand to several words, and are separated from the
+// operands next to them by spaces, unless those are Raw.
+//   ScriptPrint("rm", files)
 func ScriptPrint(vs ...interface{}) string {
-	var b strings.Builder
+	return scriptPrint(nil, nil, vs...)
+}
+
+// ScriptPrint is like the ScriptPrint function, but escapes values for the
+// shell's Interpreter.
+//
+// @StaticCompose.Group("formatters", "%sp")
+func (sh *Shell) ScriptPrint(vs ...interface{}) string {
+	return scriptPrint(sh.quote(), sh.secrets(), vs...)
+}
+
+func scriptPrint(quote func(s string) string, secrets *secretSet, vs ...interface{}) string {
+	w := &scriptWriter{quote: quote, secrets: secrets}
 	for i := 0; i < len(vs); i++ {
-		if i != 0 && !stringly(vs[i-1]) && !stringly(vs[i]) {
-			b.WriteRune(' ')
+		if i != 0 && needsSpace(vs[i-1], vs[i]) {
+			w.writeText(" ")
+		}
+		if err := w.writeValue(vs[i]); err != nil {
+			panic(err)
 		}
-		b.WriteString(string(Escape(vs[i])))
 	}
-	return b.String()
+	return w.mustScript()
 }
 
 // ScriptPrintf is like fmt.Sprintf. It takes a script format string and any
@@ -78,11 +263,55 @@ func ScriptPrint(vs ...interface{}) string {
 // formatters.
 //   ScriptPrintf(`cat %s | grep -v %s | tee log`, filename, regexp)
 //
-// @StaticCompose.Group("formatters", "%sf")
+// Values are escaped according to where they are in the script, like
+// html/template does for HTML: as a bare word or the value of an assignment,
+// inside single or double quotes, in the body of a heredoc, or as an integer
+// in arithmetic. Bash evaluates some words as arithmetic even inside quotes,
+// so values must also be integers as arguments of let and declare -i, as
+// array subscripts like arr[%s]=, and as operands of -eq and the like in
+// [[ ... ]]. ScriptPrintf panics with a *ContextError if a value can't be
+// escaped safely where it is, like inside backticks or a comment.
+//   ScriptPrintf(`echo "Hello, %s" > %s`, name, file)
 func ScriptPrintf(scriptformat string, vs ...interface{}) string {
-	escaped := make([]interface{}, len(vs))
+	return scriptPrintf(nil, nil, scriptformat, vs...)
+}
+
+// ScriptPrintf is like the ScriptPrintf function, but escapes values for the
+// shell's Interpreter.
+//
+// @StaticCompose.Group("formatters", "%sf")
+func (sh *Shell) ScriptPrintf(scriptformat string, vs ...interface{}) string {
+	return scriptPrintf(sh.quote(), sh.secrets(), scriptformat, vs...)
+}
+
+func scriptPrintf(quote func(s string) string, secrets *secretSet, scriptformat string, vs ...interface{}) string {
+	script, err := formatScript(quote, secrets, scriptformat, vs...)
+	if err != nil {
+		panic(err)
+	}
+	return script
+}
+
+// formatScript is like scriptPrintf, but returns an error if a value can't be
+// escaped.
+func formatScript(quote func(s string) string, secrets *secretSet, scriptformat string, vs ...interface{}) (string, error) {
+	var values []interface{}
+	args := make([]interface{}, len(vs))
 	for i, v := range vs {
-		escaped[i] = string(Escape(v))
+		args[i] = scriptFormatArg{v, &values}
+	}
+	formatted := fmt.Sprintf(scriptformat, args...)
+
+	w := &scriptWriter{quote: quote, secrets: secrets}
+	for i, part := range strings.Split(formatted, placeholderDelim) {
+		if i%2 == 0 {
+			w.writeText(part)
+			continue
+		}
+		n, _ := strconv.Atoi(part)
+		if err := w.writeValue(values[n]); err != nil {
+			return "", err
+		}
 	}
-	return fmt.Sprintf(scriptformat, escaped...)
+	return w.script()
 }
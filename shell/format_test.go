@@ -14,6 +14,13 @@ func TestEscape(t *testing.T) {
 		{Raw("foo bar"), "foo bar"},
 		{Raw("foo"), "foo"},
 		{Raw("foo ; bar"), "foo ; bar"},
+		{[]string{"a b", "c"}, "'a b' c"},
+		{[]interface{}{1, Raw("$x")}, "1 $x"},
+		{map[string]int{"b": 2, "a": 1}, "a=1 b=2"},
+		{Flags(map[string]interface{}{"rm": true, "quiet": false, "name": "a b"}), "'--name=a b' --rm"},
+		{MapArgs{Map: map[string]int{"n": 5}, Prefix: "-", Sep: " "}, "-n 5"},
+		{[]byte("a b"), "'a b'"},
+		{literal("a b"), "a b"},
 	}
 
 	for _, c := range cases {
@@ -32,6 +39,8 @@ func TestScriptPrint(t *testing.T) {
 		{[]interface{}{"foo", "bar"}, "foobar"},
 		{[]interface{}{"foo", "bar baz"}, "foo'bar baz'"},
 		{[]interface{}{"foo", Raw("bar baz"), "quux"}, "foobar bazquux"},
+		{[]interface{}{"rm", []string{"a b", "c"}}, "rm 'a b' c"},
+		{[]interface{}{Raw("rm "), []string{"a b", "c"}, Raw(" &&")}, "rm 'a b' c &&"},
 	}
 
 	for _, c := range cases {
@@ -51,6 +60,9 @@ func TestScriptPrintf(t *testing.T) {
 		{"%s", []interface{}{"foo bar"}, "'foo bar'"},
 		{"foo %s bar", []interface{}{"$first $last"}, "foo '$first $last' bar"},
 		{"foo %s bar", []interface{}{Raw("$first $last")}, "foo $first $last bar"},
+		{"rm %s", []interface{}{[]string{"a b", "c"}}, "rm 'a b' c"},
+		{`echo "%s"`, []interface{}{[]string{"a b", "$c"}}, `echo "a b \$c"`},
+		{"rm %s", []interface{}{[]string{}}, "rm "},
 	}
 	for _, c := range cases {
 		actual := ScriptPrintf(c.format, c.vs...)
@@ -59,3 +71,10 @@ func TestScriptPrintf(t *testing.T) {
 		}
 	}
 }
+
+// literal is an Escaper that is never quoted.
+type literal string
+
+func (l literal) Escape() Raw {
+	return Raw(l)
+}
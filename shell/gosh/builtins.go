@@ -0,0 +1,132 @@
+package gosh
+
+// Common commands implemented in Go.
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mvdan.cc/sh/v3/interp"
+)
+
+// Builtin is a command implemented in Go. args[0] is the name of the command.
+// It should read and write the Stdin, Stdout and Stderr of
+// interp.HandlerCtx(ctx), and resolve relative paths against its Dir.
+//
+// A Builtin fails by returning an error, which is printed to Stderr, and the
+// command exits 1. Return interp.NewExitStatus to exit with another status
+// without printing anything.
+type Builtin func(ctx context.Context, args []string) error
+
+// Builtins are commands that run in Go instead of as programs, by every
+// Interpreter. echo, test and [ are built into the interpreter itself.
+var Builtins = map[string]Builtin{
+	"cat":   Cat,
+	"mkdir": Mkdir,
+}
+
+// builtinHandler returns middleware that runs builtins, or Builtins, instead
+// of programs, and runs programs that aren't builtins.
+func builtinHandler(builtins map[string]Builtin) func(next interp.ExecHandlerFunc) interp.ExecHandlerFunc {
+	return func(next interp.ExecHandlerFunc) interp.ExecHandlerFunc {
+		return func(ctx context.Context, args []string) error {
+			builtin, ok := builtins[args[0]]
+			if !ok {
+				builtin, ok = Builtins[args[0]]
+			}
+			if !ok {
+				return interp.DefaultExecHandler(KillTimeout)(ctx, args)
+			}
+			err := builtin(ctx, args)
+			if _, isStatus := interp.IsExitStatus(err); err == nil || isStatus {
+				return err
+			}
+			fmt.Fprintf(interp.HandlerCtx(ctx).Stderr, "%s: %v\n", args[0], err)
+			return interp.NewExitStatus(1)
+		}
+	}
+}
+
+// absPath resolves path against the directory of the script.
+func absPath(ctx context.Context, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(interp.HandlerCtx(ctx).Dir, path)
+}
+
+// Cat writes its files, or Stdin, to Stdout, like cat(1). A file named "-"
+// is Stdin.
+func Cat(ctx context.Context, args []string) error {
+	hc := interp.HandlerCtx(ctx)
+	files := args[1:]
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	failed := false
+	for _, name := range files {
+		if err := catFile(ctx, name); err != nil {
+			fmt.Fprintf(hc.Stderr, "cat: %v\n", err)
+			failed = true
+		}
+	}
+	if failed {
+		return interp.NewExitStatus(1)
+	}
+	return nil
+}
+
+func catFile(ctx context.Context, name string) error {
+	hc := interp.HandlerCtx(ctx)
+	if name == "-" {
+		if hc.Stdin == nil {
+			return nil
+		}
+		_, err := io.Copy(hc.Stdout, hc.Stdin)
+		return err
+	}
+	f, err := os.Open(absPath(ctx, name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(hc.Stdout, f)
+	return err
+}
+
+// Mkdir creates directories, like mkdir(1). With -p, it also creates their
+// parents, and doesn't fail if they already exist.
+func Mkdir(ctx context.Context, args []string) error {
+	parents := false
+	dirs := args[1:]
+	for len(dirs) > 0 && strings.HasPrefix(dirs[0], "-") && dirs[0] != "-" {
+		flag := dirs[0]
+		dirs = dirs[1:]
+		if flag == "--" {
+			break
+		}
+		if flag != "-p" {
+			return fmt.Errorf("unsupported option %s", flag)
+		}
+		parents = true
+	}
+	if len(dirs) == 0 {
+		return fmt.Errorf("missing operand")
+	}
+	for _, dir := range dirs {
+		var err error
+		if parents {
+			err = os.MkdirAll(absPath(ctx, dir), 0777)
+		} else {
+			err = os.Mkdir(absPath(ctx, dir), 0777)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
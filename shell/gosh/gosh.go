@@ -0,0 +1,147 @@
+// Package gosh runs shell scripts in-process, with the pure Go POSIX shell
+// interpreter from mvdan.cc/sh, instead of forking Bash for each one. Scripts
+// run faster, especially in loops, and behave the same wherever the program
+// runs, whichever Bash is installed.
+//
+// Use it as the Interpreter of a Shell:
+//
+//   sh := (&shell.Shell{}).With(shell.Interp(gosh.Interpreter))
+//   files := sh.OutLines(`for f in *.go; do echo "$f"; done`)
+//
+// Shell builtins like echo, test, [, cd and printf are part of the
+// interpreter, and some common commands are implemented in Go. See Builtins.
+// Other commands are run as programs found in PATH.
+//
+// Scripts running in-process share the program's process, so they have no
+// Pid, the ProcessGroup option has no effect on them, and they can't handle
+// signals: Job.Signal and Job.Kill cancel them. A cancelled script, including
+// one that timed out, fails with exit status 137, and the programs it started
+// are killed.
+package gosh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/justjake/go-scripting/shell"
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/interp"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Interpreter runs scripts in-process, with the commands in Builtins.
+var Interpreter = New(nil)
+
+// KillTimeout is how long programs run by a cancelled script are given to
+// exit after SIGINT, before they are killed.
+var KillTimeout = 2 * time.Second
+
+// New returns an Interpreter that runs scripts in-process, with the commands
+// in Builtins, and the given builtins. Commands in builtins take precedence
+// over Builtins.
+//
+//   interp := gosh.New(map[string]gosh.Builtin{
+//     "deploy": func(ctx context.Context, args []string) error { ... },
+//   })
+func New(builtins map[string]Builtin) *shell.Interpreter {
+	return &shell.Interpreter{
+		Args:   []string{"bash", "-c"},
+		Strict: "set -euo pipefail",
+		Ext:    ".bash",
+		Run: func(ctx context.Context, cmd *exec.Cmd, script string) (int, error) {
+			return run(ctx, cmd, script, builtins)
+		},
+	}
+}
+
+func run(ctx context.Context, cmd *exec.Cmd, script string, builtins map[string]Builtin) (int, error) {
+	// Background commands in the script write concurrently, and Stdout and
+	// Stderr may be the same writer.
+	mu := &sync.Mutex{}
+	stdout, stderr := locked(mu, cmd.Stdout), locked(mu, cmd.Stderr)
+
+	file, err := syntax.NewParser().Parse(strings.NewReader(script), "")
+	if err != nil {
+		// Bash exits 2 for a syntax error.
+		fmt.Fprintf(stderr, "gosh: %v\n", err)
+		return 2, nil
+	}
+
+	stdin, closeStdin, err := stdinFile(cmd.Stdin)
+	if err != nil {
+		return 0, err
+	}
+	defer closeStdin()
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	runner, err := interp.New(
+		interp.StdIO(stdin, stdout, stderr),
+		interp.Dir(cmd.Dir),
+		interp.Env(expand.ListEnviron(env...)),
+		interp.ExecHandlers(builtinHandler(builtins)),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	err = runner.Run(ctx, file)
+	if status, ok := interp.IsExitStatus(err); ok {
+		return int(status), nil
+	}
+	return 0, err
+}
+
+// stdinFile returns r as an *os.File, which the interpreter needs so that
+// programs can share it. Other readers are copied into a pipe, which is
+// closed once the script exits, like exec.Cmd does.
+func stdinFile(r io.Reader) (*os.File, func(), error) {
+	switch r := r.(type) {
+	case nil:
+		return nil, func() {}, nil
+	case *os.File:
+		return r, func() {}, nil
+	}
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	go func() {
+		io.Copy(pw, r)
+		pw.Close()
+	}()
+	return pr, func() { pr.Close() }, nil
+}
+
+// locked returns w, or ioutil.Discard if w is nil, guarded by mu. Files are
+// returned as they are, so that programs run by the script can use them
+// directly.
+func locked(mu *sync.Mutex, w io.Writer) io.Writer {
+	switch w := w.(type) {
+	case nil:
+		return ioutil.Discard
+	case *os.File:
+		return w
+	}
+	return &lockedWriter{mu: mu, w: w}
+}
+
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(p)
+}
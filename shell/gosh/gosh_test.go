@@ -0,0 +1,167 @@
+package gosh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/justjake/go-scripting/shell"
+	"mvdan.cc/sh/v3/interp"
+)
+
+func newShell() shell.Interface {
+	return (&shell.Shell{}).With(shell.Interp(Interpreter))
+}
+
+func TestOut(t *testing.T) {
+	sh := newShell()
+	cases := []struct {
+		script string
+		out    string
+	}{
+		{`echo hello world`, "hello world"},
+		{`for i in 1 2 3; do printf %s "$i"; done`, "123"},
+		{`[ -n "x" ] && test 1 -lt 2 && echo yes`, "yes"},
+		{`echo a b | cat`, "a b"},
+		{`x=$(echo inner); echo "${x^^}"`, "INNER"},
+	}
+
+	for _, c := range cases {
+		if out := sh.Out(c.script); out != c.out {
+			t.Errorf("Out(%q) -> %q != %q", c.script, out, c.out)
+		}
+	}
+	if out := sh.Outf(`echo %s`, "it's a $HOME"); out != "it's a $HOME" {
+		t.Errorf("Outf(`echo %%s`) -> %q", out)
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	sh := newShell()
+	res := sh.Do(`echo oops >&2; exit 3`)
+	var exitErr *shell.ExitError
+	if !errors.As(res.Err(), &exitErr) || exitErr.ExitCode() != 3 || res.ExitCode != 3 {
+		t.Fatalf("Do(`exit 3`) -> %v, ExitCode %d", res.Err(), res.ExitCode)
+	}
+	if exitErr.StderrTail != "oops" {
+		t.Errorf("StderrTail -> %q", exitErr.StderrTail)
+	}
+	if res := sh.Do(`true`); res.Err() != nil || res.ExitCode != 0 {
+		t.Errorf("Do(`true`) -> %v, ExitCode %d", res.Err(), res.ExitCode)
+	}
+	if res := sh.Do(`if true; then`); res.ExitCode != 2 {
+		t.Errorf("Do with a syntax error -> ExitCode %d != 2", res.ExitCode)
+	}
+	if err := sh.With(shell.StrictMode).Run(`false | true; echo unreachable`); err == nil {
+		t.Errorf("StrictMode Run(`false | true`) should fail")
+	}
+}
+
+func TestScope(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sh := newShell().WithDir(dir).WithEnv(shell.Vars{"GREETING": "hi"})
+	if out := sh.Out(`echo "$GREETING from $(pwd)"`); out != "hi from "+dir {
+		t.Errorf("Out in %s -> %q", dir, out)
+	}
+	if out := sh.FeedString("line 1\nline 2").Out(`cat -`); out != "line 1\nline 2" {
+		t.Errorf("Out(`cat -`) with Stdin -> %q", out)
+	}
+}
+
+func TestBuiltins(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sh := newShell().WithDir(dir)
+
+	if err := sh.Run(`mkdir -p a/b/c && mkdir d && echo hello > a/b/c/file`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "d")); err != nil {
+		t.Errorf("mkdir d: %v", err)
+	}
+	if out := sh.Out(`cat a/b/c/file a/b/c/file`); out != "hello\nhello" {
+		t.Errorf("Out(`cat`) -> %q", out)
+	}
+	res := sh.Do(`mkdir d`)
+	if res.ExitCode != 1 || !strings.HasPrefix(res.Stderr, "mkdir: ") {
+		t.Errorf("mkdir of an existing dir -> %d %q", res.ExitCode, res.Stderr)
+	}
+	res = sh.Do(`cat missing a/b/c/file`)
+	if res.ExitCode != 1 || res.Stdout != "hello" || !strings.HasPrefix(res.Stderr, "cat: ") {
+		t.Errorf("cat of a missing file -> %d %q %q", res.ExitCode, res.Stdout, res.Stderr)
+	}
+
+	var calls []string
+	custom := New(map[string]Builtin{
+		"greet": func(ctx context.Context, args []string) error {
+			calls = append(calls, strings.Join(args, " "))
+			fmt.Fprintf(interp.HandlerCtx(ctx).Stdout, "hello, %s\n", args[1])
+			return nil
+		},
+		"cat": func(ctx context.Context, args []string) error {
+			return interp.NewExitStatus(4)
+		},
+	})
+	sh = sh.With(shell.Interp(custom))
+	if out := sh.Out(`greet world`); out != "hello, world" || len(calls) != 1 {
+		t.Errorf("Out(`greet world`) -> %q, calls %q", out, calls)
+	}
+	if res := sh.Do(`cat a/b/c/file`); res.ExitCode != 4 {
+		t.Errorf("custom cat -> ExitCode %d != 4", res.ExitCode)
+	}
+}
+
+func TestExecFallback(t *testing.T) {
+	sh := newShell()
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	if out := sh.Out(`/bin/sh -c 'echo $0' from-sh`); out != "from-sh" {
+		t.Errorf("Out running /bin/sh -> %q", out)
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	sh := newShell()
+	upper := shell.LineFilter(func(line string) (string, bool) {
+		return strings.ToUpper(line), true
+	})
+	out := sh.Pipe(`printf 'a\nb\n'`, upper, `cat`).Out()
+	if out != "A\nB" {
+		t.Errorf("Pipe -> %q", out)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	sh := newShell()
+	job := sh.Start(`while true; do :; done`)
+	if err := job.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	if res := job.Wait(); res.Signal != os.Kill {
+		t.Errorf("Kill -> Signal %v", res.Signal)
+	}
+
+	start := time.Now()
+	res := sh.With(shell.Timeout(50 * time.Millisecond)).Do(`while true; do :; done`)
+	if !res.TimedOut || res.Err() == nil {
+		t.Errorf("Timeout -> TimedOut %v, %v", res.TimedOut, res.Err())
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Timeout took %v", time.Since(start))
+	}
+}
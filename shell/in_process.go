@@ -0,0 +1,87 @@
+package shell
+
+// Scripts run in-process by an Interpreter with a Run function, instead of by
+// starting a command.
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+)
+
+// inProcessScript returns the script cmd runs, if the shell's Interpreter
+// runs scripts in-process and cmd is one of its commands, with the
+// Interpreter's Args followed by the script.
+func (sh *Shell) inProcessScript(cmd *exec.Cmd) (script string, ok bool) {
+	if sh.MakeCmd != nil {
+		return "", false
+	}
+	interp := sh.interpreter()
+	if interp.Run == nil || len(cmd.Args) != len(interp.Args)+1 {
+		return "", false
+	}
+	for i, arg := range interp.Args {
+		if cmd.Args[i] != arg {
+			return "", false
+		}
+	}
+	return cmd.Args[len(interp.Args)], true
+}
+
+// killCmd kills cmd, which was started by begin for res, if it is running.
+func killCmd(cmd *exec.Cmd, res *Result) error {
+	if res.cancel != nil {
+		res.cancel()
+		return nil
+	}
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
+
+// startInProcess runs script in-process with the shell's Interpreter in the
+// background, and returns a function that waits for it to finish, and one
+// that stops it. The script is also stopped when the shell's context is done,
+// or after its Timeout.
+func (sh *Shell) startInProcess(cmd *exec.Cmd, script string) (wait func() (timedOut bool, err error), cancel context.CancelFunc) {
+	parent := sh.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	var ctx context.Context
+	if sh.timeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, sh.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(parent)
+	}
+
+	type exit struct {
+		status int
+		err    error
+	}
+	exited := make(chan exit, 1)
+	run := sh.interpreter().Run
+	go func() {
+		status, err := run(ctx, cmd, script)
+		exited <- exit{status, err}
+	}()
+
+	return func() (timedOut bool, err error) {
+		e := <-exited
+		timedOut = ctx.Err() == context.DeadlineExceeded && parent.Err() == nil
+		cancel()
+		if e.err != nil && errors.Is(e.err, ctx.Err()) {
+			// A cancelled script failed, like a killed process.
+			e.status, e.err = killedStatus, nil
+		}
+		if e.err != nil || e.status == 0 {
+			return timedOut, e.err
+		}
+		return timedOut, &ExitError{Code: e.status}
+	}, cancel
+}
+
+// killedStatus is the exit status of a cancelled in-process script, which is
+// how Bash reports a command killed by SIGKILL.
+const killedStatus = 128 + 9
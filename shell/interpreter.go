@@ -0,0 +1,280 @@
+package shell
+
+// Interpreters, which know how to run a script and how to quote values in it.
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"weak"
+)
+
+// Interpreter describes a program that runs scripts, like Bash or Python. Set
+// Shell.Interpreter to run scripts with something other than DefaultArgs.
+//
+//   py := sh.With(shell.Interp(shell.Python3))
+//   py.Runf(`import sys; print(%s, file=sys.stderr)`, message)
+type Interpreter struct {
+	// Command and arguments that run a script, eg. []string{"bash", "-c"}.
+	Args []string
+	// How the script is passed to the command.
+	Invoke Invocation
+	// Quote returns s as a literal string in the interpreter's language. It is
+	// used by Escape and the other formatting methods of a Shell. Slices, maps
+	// and Argers are escaped as a list literal of quoted items, like
+	// ["a", "b"] in Python. If nil, values are quoted for a POSIX shell,
+	// according to where they are in the script. See ScriptPrintf.
+	Quote func(s string) string
+	// Code run before each script by the StrictMode option, so that the
+	// script stops at the first error. Empty if scripts are always strict.
+	Strict string
+	// Extension of the temporary file the script is written to, if Invoke is
+	// ScriptFile. Eg. ".py".
+	Ext string
+	// Run, if set, runs scripts in-process instead of starting Args. It runs
+	// script in the Dir and Env of cmd, with its Stdin, Stdout and Stderr,
+	// until ctx is done, and returns the script's exit status, or an error if
+	// the script couldn't run at all. Invoke is ignored. A script stopped
+	// because ctx is done fails with exit status 137, like a killed process
+	// in Bash.
+	//
+	// A Shell still returns an *exec.Cmd from Cmd, so that it can be passed
+	// to Pipe, for example. Commands with the Interpreter's Args followed by
+	// a script, like those from Cmd, run in-process when run by a Shell with
+	// the Interpreter, but run Args if they are run in any other way.
+	Run func(ctx context.Context, cmd *exec.Cmd, script string) (status int, err error)
+}
+
+// Invocation is a way of passing a script to an Interpreter.
+type Invocation int
+
+const (
+	// ScriptArg passes the script as the last argument, like `bash -c script`.
+	ScriptArg Invocation = iota
+	// ScriptStdin writes the script to the interpreter's Stdin, like
+	// `echo script | bash`. Scripts run this way can't read Stdin, so a shell
+	// with a Stdin can't use it, and it can't be used in a Pipeline.
+	ScriptStdin
+	// ScriptFile writes the script to a temporary file, and passes its path as
+	// the last argument, like `bash script.sh`. The file is removed once the
+	// script exits. If the command is never run by the shell, the file is
+	// removed once the command is garbage collected, or by Cleanup.
+	ScriptFile
+)
+
+// Built-in interpreters.
+var (
+	// Bash runs scripts with `bash -c`.
+	Bash = &Interpreter{
+		Args:   []string{"bash", "-c"},
+		Strict: "set -euo pipefail",
+		Ext:    ".bash",
+	}
+	// Sh runs scripts with a POSIX `sh -c`.
+	Sh = &Interpreter{
+		Args:   []string{"sh", "-c"},
+		Strict: "set -eu",
+		Ext:    ".sh",
+	}
+	// Zsh runs scripts with `zsh -c`.
+	Zsh = &Interpreter{
+		Args:   []string{"zsh", "-c"},
+		Strict: "set -euo pipefail",
+		Ext:    ".zsh",
+	}
+	// Python3 runs scripts with `python3 -c`. Values are escaped as Python
+	// string literals, and lists of values as Python lists.
+	Python3 = &Interpreter{
+		Args:  []string{"python3", "-c"},
+		Quote: quotePython,
+		Ext:   ".py",
+	}
+)
+
+var builtinInterpreters = []*Interpreter{Bash, Sh, Zsh, Python3}
+
+// Interp is an Option that runs scripts with the given Interpreter.
+//
+//   zsh := sh.With(shell.Interp(shell.Zsh))
+func Interp(interp *Interpreter) Option {
+	return func(sh *Shell) {
+		sh.Interpreter = interp
+	}
+}
+
+// StrictMode is an Option that runs the Interpreter's Strict code before each
+// script, eg. `set -euo pipefail` for Bash, so that a script fails as soon as
+// one of its commands does.
+//
+//   sh := (&shell.Shell{}).With(shell.StrictMode)
+//   sh.Run(`false; echo unreachable`)
+var StrictMode Option = func(sh *Shell) {
+	sh.strict = true
+}
+
+// interpreter returns the shell's Interpreter. Shells without one use
+// DefaultArgs, or DefaultShell, with the quoting and strict mode of the
+// built-in interpreter for the same command.
+func (sh *Shell) interpreter() *Interpreter {
+	if sh.Interpreter != nil {
+		return sh.Interpreter
+	}
+	args := sh.DefaultArgs
+	if len(args) == 0 {
+		args = DefaultShell
+	}
+	interp := &Interpreter{Args: args}
+	for _, builtin := range builtinInterpreters {
+		if builtin.Args[0] == filepath.Base(args[0]) {
+			interp.Quote = builtin.Quote
+			interp.Strict = builtin.Strict
+			interp.Ext = builtin.Ext
+		}
+	}
+	return interp
+}
+
+// quote returns the Quote function of the shell's Interpreter, which is nil
+// for POSIX shells.
+func (sh *Shell) quote() func(s string) string {
+	return sh.interpreter().Quote
+}
+
+// command returns a command that runs script with the interpreter. If a
+// ScriptFile can't be written, the command fails to start with the error.
+func (interp *Interpreter) command(ctx context.Context, script string) *exec.Cmd {
+	if len(interp.Args) == 0 {
+		panic("shell: Interpreter has no Args")
+	}
+	invoke := interp.Invoke
+	if interp.Run != nil {
+		invoke = ScriptArg
+	}
+	args := interp.Args[1:len(interp.Args):len(interp.Args)]
+	var path string
+	var writeErr error
+	switch invoke {
+	case ScriptArg:
+		args = append(args, script)
+	case ScriptFile:
+		path, writeErr = writeScriptFile(script, interp.Ext)
+		args = append(args, path)
+	}
+
+	var cmd *exec.Cmd
+	if ctx != nil {
+		cmd = exec.CommandContext(ctx, interp.Args[0], args...)
+	} else {
+		cmd = exec.Command(interp.Args[0], args...)
+	}
+	switch {
+	case invoke == ScriptStdin:
+		cmd.Stdin = strings.NewReader(script)
+	case writeErr != nil:
+		cmd.Err = fmt.Errorf("shell: could not write script file: %v", writeErr)
+	case invoke == ScriptFile:
+		addScriptFile(cmd, path)
+	}
+	return cmd
+}
+
+// strictScript prepends the Strict code of the shell's Interpreter to script,
+// if the shell has the StrictMode option.
+func (sh *Shell) strictScript(interp *Interpreter, script string) string {
+	if !sh.strict || interp.Strict == "" {
+		return script
+	}
+	return interp.Strict + "\n" + script
+}
+
+// scriptFiles tracks the temporary files written for ScriptFile interpreters
+// and mock processes that have not been removed yet. A command that is never
+// run by the shell removes its files once it is garbage collected.
+var scriptFiles = struct {
+	sync.Mutex
+	all map[weak.Pointer[exec.Cmd]]scriptFile
+}{all: make(map[weak.Pointer[exec.Cmd]]scriptFile)}
+
+type scriptFile struct {
+	paths   []string
+	cleanup runtime.Cleanup
+}
+
+// addScriptFile records that paths were written for cmd.
+func addScriptFile(cmd *exec.Cmd, paths ...string) {
+	key := weak.Make(cmd)
+	scriptFiles.Lock()
+	defer scriptFiles.Unlock()
+	scriptFiles.all[key] = scriptFile{
+		paths:   paths,
+		cleanup: runtime.AddCleanup(cmd, forgetScriptFile, key),
+	}
+}
+
+// forgetScriptFile removes the file written for a command that was garbage
+// collected, if the shell didn't remove it already.
+func forgetScriptFile(key weak.Pointer[exec.Cmd]) {
+	scriptFiles.Lock()
+	f, ok := scriptFiles.all[key]
+	delete(scriptFiles.all, key)
+	scriptFiles.Unlock()
+	if ok {
+		removeFiles(f.paths)
+	}
+}
+
+func writeScriptFile(script, ext string) (string, error) {
+	f, err := ioutil.TempFile("", "script-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.WriteString(script); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+// removeScriptFile removes the temporary files written for cmd, if any.
+func removeScriptFile(cmd *exec.Cmd) {
+	key := weak.Make(cmd)
+	scriptFiles.Lock()
+	f, ok := scriptFiles.all[key]
+	delete(scriptFiles.all, key)
+	scriptFiles.Unlock()
+	if ok {
+		f.cleanup.Stop()
+		removeFiles(f.paths)
+	}
+}
+
+func removeFiles(paths []string) {
+	for _, path := range paths {
+		os.Remove(path)
+	}
+}
+
+// removeScriptFiles removes every temporary script file. See Cleanup.
+func removeScriptFiles() {
+	scriptFiles.Lock()
+	defer scriptFiles.Unlock()
+	for key, f := range scriptFiles.all {
+		f.cleanup.Stop()
+		removeFiles(f.paths)
+		delete(scriptFiles.all, key)
+	}
+}
+
+// quotePython quotes s as a Python string literal. Go's escape sequences for
+// strings are a subset of Python's.
+func quotePython(s string) string {
+	return strconv.Quote(s)
+}
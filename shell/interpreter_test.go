@@ -0,0 +1,173 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShellEscape(t *testing.T) {
+	cases := []struct {
+		interp *Interpreter
+		in     interface{}
+		out    string
+	}{
+		{nil, "foo bar", "'foo bar'"},
+		{Sh, "it's", `it\'s`},
+		{Zsh, "foo", "foo"},
+		{Python3, "it's", `"it's"`},
+		{Python3, "a\nb", `"a\nb"`},
+		{Python3, 2, `"2"`},
+		{Python3, Raw("x + 1"), "x + 1"},
+		{Python3, []string{"a", "b"}, `["a", "b"]`},
+		{Python3, [][]string{{"a"}, {}}, `[["a"], []]`},
+		{Bash, []string{"a b", "c"}, `'a b' c`},
+	}
+
+	for _, c := range cases {
+		sh := (&Shell{}).With(Interp(c.interp))
+		actual := sh.Escape(c.in)
+		if actual != Raw(c.out) {
+			t.Errorf("Escape(%q) with %v -> %q != %q", c.in, c.interp, actual, c.out)
+		}
+	}
+}
+
+func TestStrictMode(t *testing.T) {
+	sh := &Shell{}
+	if out := sh.Out(`false | true; echo ok`); out != "ok" {
+		t.Errorf("Out(`false | true; echo ok`) -> %q != %q", out, "ok")
+	}
+	strict := sh.With(StrictMode)
+	if err := strict.Run(`false | true; echo ok`); err == nil {
+		t.Errorf("StrictMode Run(`false | true; echo ok`) should fail")
+	}
+	posix := sh.With(Interp(Sh), StrictMode)
+	if err := posix.Run(`echo $UNSET_VARIABLE`); err == nil {
+		t.Errorf("StrictMode with Sh Run(`echo $UNSET_VARIABLE`) should fail")
+	}
+}
+
+func TestInvocation(t *testing.T) {
+	for _, invoke := range []Invocation{ScriptArg, ScriptStdin, ScriptFile} {
+		interp := &Interpreter{Args: []string{"sh"}, Invoke: invoke, Quote: quotePOSIX, Ext: ".sh"}
+		if invoke == ScriptArg {
+			interp.Args = []string{"sh", "-c"}
+		}
+		sh := (&Shell{}).With(Interp(interp))
+		if out := sh.Outf(`echo %s`, "hello world"); out != "hello world" {
+			t.Errorf("Invocation %d: Outf(`echo %%s`) -> %q", invoke, out)
+		}
+	}
+}
+
+func TestScriptFileRemoved(t *testing.T) {
+	sh := (&Shell{}).with(Interp(&Interpreter{Args: []string{"sh"}, Invoke: ScriptFile}))
+	cmd := sh.Cmd(`exit 0`)
+	path := cmd.Args[len(cmd.Args)-1]
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("script file %q: %v", path, err)
+	}
+	wait, err := sh.startCmd(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wait()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("script file %q should be removed after the script exits", path)
+	}
+}
+
+func TestScriptFileErrors(t *testing.T) {
+	t.Setenv("TMPDIR", "/nonexistent")
+	sh := (&Shell{ErrorPolicy: ReturnErrors}).With(Interp(&Interpreter{Args: []string{"sh"}, Invoke: ScriptFile}))
+	if err := sh.Run(`exit 0`); err == nil || !strings.Contains(err.Error(), "could not write script file") {
+		t.Errorf("Run(...) -> %v, expected the error writing the script file", err)
+	}
+}
+
+func TestScriptFileCollected(t *testing.T) {
+	sh := (&Shell{}).With(Interp(&Interpreter{Args: []string{"sh"}, Invoke: ScriptFile}))
+	path := func() string {
+		cmd := sh.Cmd(`exit 0`)
+		return cmd.Args[len(cmd.Args)-1]
+	}()
+	for i := 0; i < 100; i++ {
+		runtime.GC()
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Errorf("script file %q should be removed once its command is garbage collected", path)
+}
+
+func TestPython3(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not found")
+	}
+	sh := (&Shell{}).With(Interp(Python3))
+	name := `O'Brien "the shell" \ $HOME`
+	if out := sh.Outf(`print(%s)`, name); out != name {
+		t.Errorf("Outf(`print(%%s)`) -> %q != %q", out, name)
+	}
+	if out := sh.Outf(`print(len(%s))`, []string{"a", "b"}); out != "2" {
+		t.Errorf("Outf(`print(len(%%s))`) -> %q != %q", out, "2")
+	}
+}
+
+func TestInterpreterRun(t *testing.T) {
+	var ran []string
+	interp := &Interpreter{
+		Args: []string{"false"},
+		Run: func(ctx context.Context, cmd *exec.Cmd, script string) (int, error) {
+			ran = append(ran, script)
+			if script == "block" {
+				<-ctx.Done()
+				return 0, ctx.Err()
+			}
+			if script == "status 300" {
+				return 300, nil
+			}
+			fmt.Fprintf(cmd.Stdout, "ran %s in %s", script, cmd.Dir)
+			return len(script) % 2, nil
+		},
+	}
+	sh := (&Shell{ErrorPolicy: ReturnErrors}).With(Interp(interp), Dir("/tmp"))
+
+	if out := sh.Out(`ok`); out != "ran ok in /tmp" {
+		t.Errorf("Out(`ok`) -> %q", out)
+	}
+	res := sh.Do(`odd`)
+	if exitErr, ok := res.Err().(*ExitError); !ok || exitErr.ExitCode() != 1 || res.ExitCode != 1 || res.Pid != 0 {
+		t.Errorf("Do(`odd`) -> %v, ExitCode %d, Pid %d", res.Err(), res.ExitCode, res.Pid)
+	}
+	var execErr *exec.ExitError
+	if !errors.As(res.Err(), &execErr) || execErr.ExitCode() != 1 {
+		t.Errorf("errors.As(Do(`odd`).Err(), *exec.ExitError) -> %v", execErr)
+	}
+
+	job := sh.Start(`block`)
+	job.Kill()
+	if res := job.Wait(); res.ExitCode != killedStatus || res.Signal != os.Kill {
+		t.Errorf("killed job -> ExitCode %d, Signal %v", res.ExitCode, res.Signal)
+	}
+	if res := sh.With(Timeout(10 * time.Millisecond)).Do(`block`); !res.TimedOut || res.ExitCode != killedStatus {
+		t.Errorf("timed out script -> TimedOut %v, ExitCode %d", res.TimedOut, res.ExitCode)
+	}
+	if res := sh.Do(`status 300`); res.ExitCode != 300 || res.Err().(*ExitError).ExitCode() != 300 {
+		t.Errorf("Do(`status 300`) -> ExitCode %d, %v", res.ExitCode, res.Err())
+	}
+	if err := sh.Cmd(`ok`).Run(); err == nil {
+		t.Errorf("Cmd(`ok`).Run() should run Args, not the script in-process")
+	}
+	if len(ran) != 5 {
+		t.Errorf("ran %q", ran)
+	}
+}
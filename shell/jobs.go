@@ -0,0 +1,293 @@
+package shell
+
+// Background jobs, like `script &` in Bash.
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+)
+
+// Job is a script running in the background. See Start.
+type Job struct {
+	// The script run by the job.
+	Script string
+
+	sh     *Shell
+	cmd    *exec.Cmd
+	res    *Result
+	done   chan struct{}
+	output jobOutput
+
+	mu     sync.Mutex
+	killed bool
+}
+
+// jobOutput collects the last jobOutputBytes of a job's output while it runs.
+type jobOutput struct {
+	mu       sync.Mutex
+	stdout   bytes.Buffer
+	stderr   bytes.Buffer
+	combined bytes.Buffer
+}
+
+type jobWriter struct {
+	output *jobOutput
+	buf    *bytes.Buffer
+}
+
+// Bytes of each of Stdout, Stderr and their combination kept by a Job.
+const jobOutputBytes = 1 << 20
+
+func (w jobWriter) Write(p []byte) (int, error) {
+	w.output.mu.Lock()
+	defer w.output.mu.Unlock()
+	keepTail(w.buf, p)
+	keepTail(&w.output.combined, p)
+	return len(p), nil
+}
+
+// keepTail writes p to buf, and drops the start of buf if it grows past
+// jobOutputBytes.
+func keepTail(buf *bytes.Buffer, p []byte) {
+	buf.Write(p)
+	if buf.Len() > jobOutputBytes {
+		buf.Next(buf.Len() - jobOutputBytes)
+	}
+}
+
+// Start runs the script in the background, and returns a Job to control it.
+// The last megabyte of each of the script's Stdout and Stderr is collected in
+// the Job, and all of it is also written to the shell's Stdout and Stderr if
+// they are set. The job is added to the shell's job table until it is waited
+// for. See Jobs.
+//
+//   server := sh.Start(`./bin/server --port 8080`)
+//   defer server.Kill()
+//   sh.Must().Run(`go test ./integration/...`)
+//
+// @StaticCompose.Inside("formatters")
+func (sh *Shell) Start(script string) *Job {
+	return sh.startJob(sh.Cmd(script), script)
+}
+
+// startJob starts cmd as a background Job.
+func (sh *Shell) startJob(cmd *exec.Cmd, script string) *Job {
+	j := &Job{
+		Script: script,
+		sh:     sh,
+		cmd:    cmd,
+		res:    &Result{Script: script},
+		done:   make(chan struct{}),
+	}
+	var stdout, stderr io.Writer = jobWriter{&j.output, &j.output.stdout}, jobWriter{&j.output, &j.output.stderr}
+	if sh.Stdout != nil {
+		stdout = io.MultiWriter(stdout, sh.Stdout)
+	}
+	if sh.Stderr != nil {
+		stderr = io.MultiWriter(stderr, sh.Stderr)
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	state := sh.state()
+	state.mu.Lock()
+	state.jobs = append(state.jobs, j)
+	state.mu.Unlock()
+
+	wait := sh.begin(cmd, j.res)
+	runsInProcess := j.res.cancel != nil
+	go func() {
+		defer close(j.done)
+		wait()
+		j.mu.Lock()
+		if runsInProcess && j.killed && j.res.err != nil {
+			// In-process scripts are stopped without a signal.
+			j.res.Signal = os.Kill
+		}
+		j.mu.Unlock()
+		j.output.mu.Lock()
+		j.res.Stdout = sh.trim(j.output.stdout.Bytes())
+		j.res.Stderr = sh.trim(j.output.stderr.Bytes())
+		j.res.Combined = sh.trim(j.output.combined.Bytes())
+		j.output.mu.Unlock()
+		j.res.err = wrapExitError(j.res.err, script, cmd.Dir, j.res.Stderr)
+		sh.trace(TraceFinish, cmd.Dir, j.res)
+	}()
+	return j
+}
+
+// Wait waits for the job to exit, removes it from the shell's job table, and
+// returns a Result describing it. Like Run, a failed job is handled by the
+// shell's ErrorPolicy, unless the job was stopped with Kill.
+func (j *Job) Wait() *Result {
+	<-j.done
+	j.sh.forgetJob(j)
+	if j.wasKilled() {
+		return j.res
+	}
+	j.sh.onError(j.res.err)
+	return j.res
+}
+
+// wasKilled returns true if the job exited because of Kill.
+func (j *Job) wasKilled() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.killed && j.res.Signal != nil
+}
+
+// Done returns a channel that is closed once the job exits.
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Pid returns the process ID of the job, or 0 if it could not be started or
+// runs in-process.
+func (j *Job) Pid() int {
+	return j.res.Pid
+}
+
+// Signal sends sig to the job's script. It returns os.ErrProcessDone if the
+// job has already exited, or an error if it could not be started. A script
+// running in-process can't handle signals, so any signal stops it.
+func (j *Job) Signal(sig os.Signal) error {
+	if j.exited() {
+		return os.ErrProcessDone
+	}
+	if j.res.cancel != nil {
+		j.res.cancel()
+		return nil
+	}
+	if j.cmd.Process == nil {
+		return j.res.err
+	}
+	// Process.Signal won't signal a process once it was reaped.
+	return j.cmd.Process.Signal(sig)
+}
+
+// Kill kills the job, along with its whole process group if it has one. See
+// ProcessGroup. A job stopped with Kill is not considered to have failed by
+// Wait or WaitJobs. Like Signal, it returns os.ErrProcessDone if the job has
+// already exited.
+func (j *Job) Kill() error {
+	if j.exited() {
+		return os.ErrProcessDone
+	}
+	j.mu.Lock()
+	j.killed = true
+	j.mu.Unlock()
+	if j.res.cancel != nil {
+		j.res.cancel()
+		return nil
+	}
+	if j.cmd.Process == nil {
+		return j.res.err
+	}
+	if j.sh.usesProcessGroup() {
+		return signalRunningGroup(j.cmd.Process, syscall.SIGKILL)
+	}
+	return j.cmd.Process.Kill()
+}
+
+// exited returns true if the job has exited.
+func (j *Job) exited() bool {
+	select {
+	case <-j.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Stdout returns what the job has written to Stdout so far, up to its last
+// megabyte.
+func (j *Job) Stdout() string {
+	j.output.mu.Lock()
+	defer j.output.mu.Unlock()
+	return j.output.stdout.String()
+}
+
+// Stderr returns what the job has written to Stderr so far, up to its last
+// megabyte.
+func (j *Job) Stderr() string {
+	j.output.mu.Lock()
+	defer j.output.mu.Unlock()
+	return j.output.stderr.String()
+}
+
+// Output returns what the job has written to Stdout and Stderr so far,
+// interleaved roughly in the order it was written, up to its last megabyte.
+func (j *Job) Output() string {
+	j.output.mu.Lock()
+	defer j.output.mu.Unlock()
+	return j.output.combined.String()
+}
+
+// Jobs returns the shell's background jobs that have not been waited for, in
+// the order they were started, like `jobs` in Bash. Jobs are shared with
+// shells derived by With.
+func (sh *Shell) Jobs() []*Job {
+	state := sh.state()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return append([]*Job(nil), state.jobs...)
+}
+
+// WaitJobs waits for all of the shell's background jobs, like `wait` in Bash.
+// It returns a *ParallelError describing each job that failed, or nil if they
+// all succeeded.
+func (sh *Shell) WaitJobs() error {
+	jobs := sh.Jobs()
+	failed := &ParallelError{Total: len(jobs)}
+	for _, j := range jobs {
+		res := j.waitQuietly()
+		if res.Success() || j.wasKilled() {
+			continue
+		}
+		failed.Failures = append(failed.Failures, &ParallelFailure{
+			Label:      scriptLabel(j.Script),
+			Script:     j.Script,
+			Err:        res.err,
+			StderrTail: lastLines(res.Stderr, stderrTailLines),
+		})
+	}
+	if len(failed.Failures) == 0 {
+		return nil
+	}
+	sh.errorPolicy().HandleError(failed)
+	return failed
+}
+
+// KillJobs kills all of the shell's background jobs that are still running.
+func (sh *Shell) KillJobs() {
+	for _, j := range sh.Jobs() {
+		select {
+		case <-j.done:
+		default:
+			j.Kill()
+		}
+	}
+}
+
+// waitQuietly is Wait, without handling the job's error.
+func (j *Job) waitQuietly() *Result {
+	<-j.done
+	j.sh.forgetJob(j)
+	return j.res
+}
+
+func (sh *Shell) forgetJob(j *Job) {
+	state := sh.state()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	for i, job := range state.jobs {
+		if job == j {
+			state.jobs = append(state.jobs[:i], state.jobs[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package shell
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStart(t *testing.T) {
+	sh := (&Shell{}).Must()
+	server := sh.Start(`echo ready; sleep 5`)
+	if server.Pid() == 0 {
+		t.Errorf("Start(...).Pid() -> 0")
+	}
+	for start := time.Now(); !strings.Contains(server.Stdout(), "ready"); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 2*time.Second {
+			t.Fatalf("Start(...).Stdout() -> %q, expected ready", server.Stdout())
+		}
+	}
+	if jobs := sh.Jobs(); len(jobs) != 1 || jobs[0] != server {
+		t.Errorf("Jobs() -> %v, expected the server", jobs)
+	}
+
+	server.Kill()
+	select {
+	case <-server.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Kill() did not stop the job")
+	}
+	// Does not panic, because the job was killed on purpose.
+	res := server.Wait()
+	if res.Signal == nil || res.Stdout != "ready" {
+		t.Errorf("Wait() -> Signal %v, Stdout %q", res.Signal, res.Stdout)
+	}
+	if jobs := sh.Jobs(); len(jobs) != 0 {
+		t.Errorf("Jobs() -> %v after Wait, expected none", jobs)
+	}
+	if err := server.Kill(); err != os.ErrProcessDone {
+		t.Errorf("Kill() after Wait -> %v", err)
+	}
+}
+
+func TestJobGroupKilledAfterExit(t *testing.T) {
+	job := (&Shell{}).With(ProcessGroup).Start(`true`)
+	job.Wait()
+	if err := job.Kill(); err != os.ErrProcessDone {
+		t.Errorf("Kill() of an exited job in a process group -> %v", err)
+	}
+	if err := job.Signal(os.Interrupt); err != os.ErrProcessDone {
+		t.Errorf("Signal(...) of an exited job -> %v", err)
+	}
+}
+
+func TestJobOutputLimit(t *testing.T) {
+	job := (&Shell{}).Startf(`head -c %s /dev/zero; echo end`, jobOutputBytes+10)
+	res := job.Wait()
+	if len(job.Stdout()) != jobOutputBytes || !strings.HasSuffix(job.Stdout(), "end\n") {
+		t.Errorf("Stdout() kept %d bytes, expected the last %d", len(job.Stdout()), jobOutputBytes)
+	}
+	if len(res.Combined) != jobOutputBytes-1 {
+		t.Errorf("Wait().Combined kept %d bytes", len(res.Combined))
+	}
+}
+
+func TestWaitJobs(t *testing.T) {
+	sh := &Shell{}
+	sh.Start(`true`)
+	sh.Startf(`echo %s >&2; exit 3`, "oops")
+	sh.Start(`sleep 5`).Kill()
+
+	err := sh.WaitJobs()
+	perr, ok := err.(*ParallelError)
+	if !ok || len(perr.Failures) != 1 || perr.Failures[0].StderrTail != "oops" {
+		t.Errorf("WaitJobs() -> %v, expected only `exit 3` to fail", err)
+	}
+	if jobs := sh.Jobs(); len(jobs) != 0 {
+		t.Errorf("Jobs() -> %v after WaitJobs, expected none", jobs)
+	}
+}
@@ -0,0 +1,303 @@
+package shell
+
+// Flexible expectations for MockShell, for when exact scripts are too brittle.
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	shellquote "github.com/kballard/go-shellquote"
+)
+
+// ScriptMatcher decides if a MockShell expectation applies to a script.
+type ScriptMatcher interface {
+	MatchScript(script string) bool
+}
+
+// scriptMatcher implements the built-in ScriptMatchers.
+type scriptMatcher struct {
+	kind    string
+	pattern string
+	match   func(script string) bool
+}
+
+func (m *scriptMatcher) MatchScript(script string) bool {
+	return m.match(script)
+}
+
+func (m *scriptMatcher) String() string {
+	return fmt.Sprintf("%s %q", m.kind, m.pattern)
+}
+
+// MatchExact matches a script exactly, like MockShell.Mocks.
+func MatchExact(script string) ScriptMatcher {
+	return &scriptMatcher{"exact", script, func(s string) bool {
+		return s == script
+	}}
+}
+
+// MatchRegexp matches scripts that contain a match of the regular expression.
+// It panics if the pattern is not valid.
+func MatchRegexp(pattern string) ScriptMatcher {
+	re := regexp.MustCompile(pattern)
+	return &scriptMatcher{"regexp", pattern, re.MatchString}
+}
+
+// MatchGlob matches whole scripts using a glob pattern, where * matches any
+// sequence of characters (including / and spaces), and ? matches any single
+// character.
+//
+//   MatchGlob("kubectl get pods -n * -o json")
+func MatchGlob(pattern string) ScriptMatcher {
+	var re strings.Builder
+	re.WriteString(`^`)
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			re.WriteString(`.*`)
+		case '?':
+			re.WriteString(`.`)
+		default:
+			re.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	re.WriteString(`$`)
+	return &scriptMatcher{"glob", pattern, regexp.MustCompile(re.String()).MatchString}
+}
+
+// MatchArgv matches scripts that split into the same words as argv, in the
+// same order, so differences in quoting don't matter. Flags are not
+// reordered, because a flag's value can't be told apart from a positional
+// word. Scripts that can't be split never match.
+//
+//   // matches `git log -n 5 --oneline` and `git log -n '5' "--oneline"`
+//   MatchArgv("git", "log", "-n", "5", "--oneline")
+func MatchArgv(argv ...string) ScriptMatcher {
+	expected := append([]string(nil), argv...)
+	return &scriptMatcher{"argv", shellquote.Join(argv...), func(s string) bool {
+		words, err := shellquote.Split(s)
+		if err != nil || len(words) != len(expected) {
+			return false
+		}
+		for i, word := range words {
+			if word != expected[i] {
+				return false
+			}
+		}
+		return true
+	}}
+}
+
+// MatchFunc matches scripts for which fn returns true.
+func MatchFunc(fn func(script string) bool) ScriptMatcher {
+	return &scriptMatcher{"func", "", fn}
+}
+
+// Expectation is a MockShell mock that uses a ScriptMatcher. Create one with
+// MockShell.Expect.
+type Expectation struct {
+	Matcher ScriptMatcher
+	// The mocked result. Its Script is ignored.
+	Call  MockCall
+	times int
+	stdin *string
+
+	// Guards the uses of the expectation, which may be read while a MockShell
+	// is used from other goroutines.
+	mu     sync.Mutex
+	calls  int
+	stdins []string
+}
+
+// Times limits the expectation to be used exactly n times. By default, an
+// expectation may be used any number of times, but at least once.
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+// WithStdin requires scripts matched by the expectation to receive exactly
+// stdin. A script that receives something else panics with a diff.
+//
+//   sh.Expect(MatchExact(`kubectl apply -f -`), MockCall{}).WithStdin(manifest)
+func (e *Expectation) WithStdin(stdin string) *Expectation {
+	e.stdin = &stdin
+	return e
+}
+
+// Stdins returns the stdin received by each use of the expectation.
+func (e *Expectation) Stdins() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.stdins...)
+}
+
+// Calls returns how many times the expectation was used.
+func (e *Expectation) Calls() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.calls
+}
+
+func (e *Expectation) use() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calls++
+}
+
+func (e *Expectation) addStdin(stdin string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stdins = append(e.stdins, stdin)
+}
+
+func (e *Expectation) exhausted() bool {
+	calls := e.Calls()
+	return e.times > 0 && calls >= e.times
+}
+
+func (e *Expectation) satisfied() bool {
+	calls := e.Calls()
+	if e.times > 0 {
+		return calls == e.times
+	}
+	return calls > 0
+}
+
+func (e *Expectation) String() string {
+	return fmt.Sprint(e.Matcher)
+}
+
+// Expect adds an expectation that scripts matching matcher will return call.
+// Expectations are checked after any exact Mocks.
+//
+//   sh.Expect(MatchRegexp(`^kubectl get pods\b`), MockCall{Stdout: "api-1"}).Times(2)
+func (sh *MockShell) Expect(matcher ScriptMatcher, call MockCall) *Expectation {
+	e := &Expectation{Matcher: matcher, Call: call}
+	sh.Expectations = append(sh.Expectations, e)
+	return e
+}
+
+// TestingT is the subset of *testing.T used by MockShell assertions.
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+// AssertAllCalled reports an error to t for each expectation that was not
+// used as many times as required, and for each exact mock that was not used.
+func (sh *MockShell) AssertAllCalled(t TestingT) {
+	progress := sh.mockProgress()
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+	for _, e := range sh.Expectations {
+		if !e.satisfied() {
+			if e.times > 0 {
+				t.Errorf("Expected %v to be called %d times, but it was called %d times", e, e.times, e.Calls())
+			} else {
+				t.Errorf("Expected %v to be called, but it was not", e)
+			}
+		}
+	}
+	if sh.LoopMocks {
+		return
+	}
+	scripts := make([]string, 0, len(sh.Mocks))
+	for script := range sh.Mocks {
+		scripts = append(scripts, script)
+	}
+	sort.Strings(scripts)
+	for _, script := range scripts {
+		if used := progress.mocks[script]; used < len(sh.Mocks[script]) {
+			t.Errorf("Expected %d calls of script %q, but got %d", len(sh.Mocks[script]), script, used)
+		}
+	}
+}
+
+// popExpectation uses and returns the expectation for script, or nil. The
+// caller must hold the progress lock.
+func (sh *MockShell) popExpectation(script string) *Expectation {
+	if !sh.Ordered {
+		for _, e := range sh.Expectations {
+			if !e.exhausted() && e.Matcher.MatchScript(script) {
+				e.use()
+				return e
+			}
+		}
+		return nil
+	}
+
+	// In order: use the current expectation, or move on to the next once the
+	// current one is satisfied.
+	progress := sh.progress
+	for progress.nextExpectation < len(sh.Expectations) {
+		e := sh.Expectations[progress.nextExpectation]
+		if !e.exhausted() && e.Matcher.MatchScript(script) {
+			e.use()
+			return e
+		}
+		if !e.satisfied() {
+			return nil
+		}
+		progress.nextExpectation++
+	}
+	return nil
+}
+
+// unmatched describes why no mock matched script, including a diff against
+// the most similar mock.
+func (sh *MockShell) unmatched(script string) error {
+	msg := fmt.Sprintf("No mocks configured for script: %s", script)
+
+	nearest, nearestPattern, distance := "", "", -1
+	consider := func(desc, pattern string) {
+		if d := editDistance(pattern, script); distance < 0 || d < distance {
+			nearest, nearestPattern, distance = desc, pattern, d
+		}
+	}
+	for mocked := range sh.Mocks {
+		consider(fmt.Sprintf("exact %q", mocked), mocked)
+	}
+	for _, e := range sh.Expectations {
+		if m, ok := e.Matcher.(*scriptMatcher); ok && m.pattern != "" {
+			consider(e.String(), m.pattern)
+		}
+	}
+	if distance < 0 {
+		return fmt.Errorf("%s", msg)
+	}
+	return fmt.Errorf("%s\nNearest mock: %s\n%s", msg, nearest, diffLines(nearestPattern, script))
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
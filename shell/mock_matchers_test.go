@@ -0,0 +1,126 @@
+package shell
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestScriptMatchers(t *testing.T) {
+	cases := []struct {
+		matcher ScriptMatcher
+		script  string
+		match   bool
+	}{
+		{MatchExact("ls -la"), "ls -la", true},
+		{MatchExact("ls -la"), "ls  -la", false},
+		{MatchRegexp(`^kubectl get pods\b`), "kubectl get pods -n prod", true},
+		{MatchRegexp(`^kubectl get pods\b`), "kubectl get podsecuritypolicies", false},
+		{MatchGlob("cp * /tmp/*"), "cp 'a b' /tmp/c/d", true},
+		{MatchGlob("cp ?"), "cp ab", false},
+		{MatchArgv("git", "log", "-n", "5", "--oneline"), `git log -n '5' "--oneline"`, true},
+		{MatchArgv("git", "log", "-n", "5", "--oneline"), "git log --oneline -n 5", false},
+		{MatchArgv("grep", "-e", "a", "-v", "b"), "grep -e b -v a", false},
+		{MatchArgv("cp", "a", "b"), "cp b a", false},
+		{MatchArgv("echo", "a"), "echo 'a", false},
+		{MatchFunc(func(s string) bool { return len(s) == 3 }), "pwd", true},
+	}
+
+	for _, c := range cases {
+		if actual := c.matcher.MatchScript(c.script); actual != c.match {
+			t.Errorf("%v.MatchScript(%q) -> %v != %v", c.matcher, c.script, actual, c.match)
+		}
+	}
+}
+
+type fakeT struct {
+	errors []string
+}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestExpect(t *testing.T) {
+	sh := &MockShell{}
+	sh.AddMock(MockCall{Script: "hostname", Stdout: "exact"})
+	sh.Expect(MatchGlob("host*"), MockCall{Stdout: "glob"}).Times(1)
+	date := sh.Expect(MatchRegexp("^date"), MockCall{ExitStatus: 1})
+
+	outs := []string{sh.Out("hostname"), sh.Out("hostname")}
+	if strings.Join(outs, ",") != "exact,glob" {
+		t.Errorf("Out(hostname) -> %#v", outs)
+	}
+
+	check := &fakeT{}
+	sh.AssertAllCalled(check)
+	if len(check.errors) != 1 || !strings.Contains(check.errors[0], `regexp "^date"`) {
+		t.Errorf("AssertAllCalled -> %#v", check.errors)
+	}
+
+	if sh.Succeeds("date +%s") || date.Calls() != 1 {
+		t.Errorf("Expected date to fail once")
+	}
+
+	defer func() {
+		err := recover().(error)
+		expected := "No mocks configured for script: hostnamectl\nNearest mock: exact \"hostname\"\n- hostname\n+ hostnamectl\n"
+		if err.Error() != expected {
+			t.Errorf("%q != %q", err.Error(), expected)
+		}
+	}()
+	sh.Out("hostnamectl")
+	t.Errorf("Out should panic once the glob is exhausted")
+}
+
+func TestExpectConcurrent(t *testing.T) {
+	sh := &MockShell{}
+	apply := sh.Expect(MatchExact("kubectl apply -f -"), MockCall{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			apply.Calls()
+			apply.Stdins()
+		}
+	}()
+	sh.ForEach([]string{"a", "b", "c"}, 0, func(sh Interface, manifest string) error {
+		return sh.FeedString(manifest).Run("kubectl apply -f -")
+	})
+	<-done
+
+	stdins := apply.Stdins()
+	sort.Strings(stdins)
+	if apply.Calls() != 3 || !reflect.DeepEqual(stdins, []string{"a", "b", "c"}) {
+		t.Errorf("Calls() -> %d, Stdins() -> %q", apply.Calls(), stdins)
+	}
+	stdins[0] = "changed"
+	if apply.Stdins()[0] == "changed" {
+		t.Errorf("Stdins() should return a copy")
+	}
+}
+
+func TestExpectOrdered(t *testing.T) {
+	sh := &MockShell{Ordered: true, AllowUnmocked: true}
+	sh.Expect(MatchArgv("git", "fetch"), MockCall{}).Times(2)
+	sh.Expect(MatchArgv("git", "rev-parse", "HEAD"), MockCall{Stdout: "abc"})
+
+	sh.Exec("git", "fetch")
+	// rev-parse is out of order, so the script should really run.
+	sh.DefaultArgs = []string{"echo"}
+	if out := sh.Out("git rev-parse HEAD"); out != "git rev-parse HEAD" {
+		t.Errorf("Out -> %q", out)
+	}
+	sh.Exec("git", "fetch")
+	if out := sh.ExecOut("git", "rev-parse", "HEAD"); out != "abc" {
+		t.Errorf("ExecOut -> %q", out)
+	}
+
+	check := &fakeT{}
+	sh.AssertAllCalled(check)
+	if len(check.errors) != 0 {
+		t.Errorf("AssertAllCalled -> %#v", check.errors)
+	}
+}
@@ -0,0 +1,52 @@
+package shell
+
+// Mock processes run a small sh script to emulate a MockCall, which makes it
+// possible for a MockShell to return genuine *exec.Cmds.
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+// mockProcessScript writes the output of a mock call, passed in temporary
+// files, and exits with its status.
+const mockProcessScript = `cat "$1"; cat "$2" >&2; exit "$3"`
+
+// Cmd returns an *exec.Cmd that, when run, writes the call's Stdout and Stderr
+// (each followed by a newline, unless empty) and exits with its ExitStatus. The
+// command runs sh, so it needs a POSIX shell, like the scripts it stands in for.
+//
+// The output is passed to the process in temporary files, which are removed
+// like those of a ScriptFile Interpreter. Cmd panics if the ExitStatus is not
+// between 0 and 255, since a process can't exit with it.
+func (call MockCall) Cmd() *exec.Cmd {
+	if call.ExitStatus < 0 || call.ExitStatus > 255 {
+		panic(fmt.Errorf("MockCall.Cmd: exit status %d of %q is not between 0 and 255", call.ExitStatus, call.Script))
+	}
+	line := func(s string) string {
+		if s == "" {
+			return s
+		}
+		return s + "\n"
+	}
+	var paths []string
+	var writeErr error
+	for _, output := range []string{call.Stdout, call.Stderr} {
+		path, err := writeScriptFile(line(output), ".out")
+		if err != nil {
+			writeErr = err
+			break
+		}
+		paths = append(paths, path)
+	}
+	if writeErr != nil {
+		removeFiles(paths)
+		cmd := exec.Command("sh")
+		cmd.Err = fmt.Errorf("shell: could not write mock output: %v", writeErr)
+		return cmd
+	}
+	cmd := exec.Command("sh", "-c", mockProcessScript, "sh", paths[0], paths[1], strconv.Itoa(call.ExitStatus))
+	addScriptFile(cmd, paths...)
+	return cmd
+}
@@ -0,0 +1,68 @@
+package shell
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMockShellCmd(t *testing.T) {
+	sh := &MockShell{}
+	sh.AddMock(MockCall{Script: "cat /etc/hostname", Stdout: "example", Stderr: "warning", ExitStatus: 3})
+
+	cmd := sh.Cmdp(Raw("cat "), "/etc/hostname")
+	out, err := cmd.Output()
+	if string(out) != "example\n" {
+		t.Errorf("Output() -> %q", out)
+	}
+	if cmd.ProcessState.ExitCode() != 3 || err == nil {
+		t.Errorf("Output() -> exit status %d, %v", cmd.ProcessState.ExitCode(), err)
+	}
+}
+
+func TestMockCallCmd(t *testing.T) {
+	big := strings.Repeat("x", 4<<20)
+	cmd := MockCall{Stdout: big}.Cmd()
+	paths := cmd.Args[len(cmd.Args)-3 : len(cmd.Args)-1]
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if _, err := (&Shell{}).runCmd(cmd); err != nil || out.Len() != len(big)+1 {
+		t.Errorf("running Cmd() with %d bytes of output -> %d bytes, %v", len(big), out.Len(), err)
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("output file %q should be removed after the mock process exits", path)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Cmd() with exit status 300 should panic")
+		}
+	}()
+	MockCall{ExitStatus: 300}.Cmd()
+}
+
+func TestMockShellPipe(t *testing.T) {
+	sh := &MockShell{AllowUnmocked: true}
+	sh.AddMock(MockCall{Script: "kubectl get pods -o name", Stdout: "pod/a\npod/b"})
+
+	out := sh.Pipe("kubectl get pods -o name", "tail -1").Out()
+	if out != "pod/b" {
+		t.Errorf("Pipe(...).Out() -> %q", out)
+	}
+}
+
+func TestMockCallExitError(t *testing.T) {
+	err := MockCall{ExitStatus: 42}.ExitError()
+	if err.ExitCode() != 42 {
+		t.Errorf("ExitError().ExitCode() -> %d", err.ExitCode())
+	}
+	if err.Unwrap() != nil {
+		t.Errorf("ExitError().Unwrap() -> %v, expected no process", err.Unwrap())
+	}
+	if err := (MockCall{ExitStatus: 300}).ExitError(); err.ExitCode() != 300 || err.Error() != `Script "" failed with exit status 300` {
+		t.Errorf("ExitError() -> %d, %q", err.ExitCode(), err.Error())
+	}
+}
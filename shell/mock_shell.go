@@ -1,12 +1,17 @@
 package shell
 
 // An attempt to write a mocked implementation of Shell.
-// This is probably the wrong way to go:
-// Read https://npf.io/2015/06/testing-exec-command/
+// Methods that return an *exec.Cmd use mock processes, which print the mocked
+// output and exit with the mocked status. See mock_process.go.
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os/exec"
+	"strings"
+	"sync"
+	"time"
 )
 
 // MockShell can be substituted for a shell for testing purposes.
@@ -22,12 +27,54 @@ import (
 //   if res == nil {
 //     panic(fmt.Sprintf("Expected res to be an exit error w/ status 128"))
 //   }
+//
+// Mocked Run and Exec calls write the mocked output to the shell's Stdout and
+// Stderr, if they are set, like a script would.
+//
+// Argv methods like Exec are mocked using the script shellquote.Join(argv...),
+// so a mock for sh.Exec("echo", "hello world") has the Script "echo 'hello world'".
 type MockShell struct {
 	Shell
 	Mocks         map[string][]MockCall
-	mockProgress  map[string]int
 	AllowUnmocked bool
 	LoopMocks     bool
+	// Checked after Mocks. See Expect.
+	Expectations []*Expectation
+	// If true, Expectations must be used in the order they were added.
+	Ordered bool
+	// If set, scripts are replayed from the cassette instead of Mocks.
+	Cassette *Cassette
+	// Progress through the mocks, shared with mock shells derived by Feed.
+	progress *mockProgress
+}
+
+// mockProgresses guards the progress field of every MockShell, which is
+// allocated lazily so that the zero MockShell is ready to use.
+var mockProgresses sync.Mutex
+
+// mockProgress records which mocks a MockShell has used. It has its own lock,
+// so a MockShell can be shared between goroutines like a Shell.
+type mockProgress struct {
+	mu sync.Mutex
+	// How many of the Mocks for each script were used.
+	mocks map[string]int
+	// Progress through ordered expectations
+	nextExpectation int
+	// Stdin received by each mocked script.
+	stdins map[string][]string
+}
+
+// mockProgress returns the shell's progress, allocating it if needed.
+func (sh *MockShell) mockProgress() *mockProgress {
+	mockProgresses.Lock()
+	defer mockProgresses.Unlock()
+	if sh.progress == nil {
+		sh.progress = &mockProgress{
+			mocks:  make(map[string]int),
+			stdins: make(map[string][]string),
+		}
+	}
+	return sh.progress
 }
 
 // AddMock adds a pushes a call to this mock shell for the script.
@@ -48,13 +95,46 @@ func (sh *MockShell) AddMock(call MockCall) *MockShell {
 	return sh
 }
 
+// Cmd returns a command that emulates the mock for script when run. See
+// MockCall.Cmd.
+//
+// @StaticCompose.Inside("formatters")
+func (sh *MockShell) Cmd(script string) *exec.Cmd {
+	res := sh.popMock(script)
+	if res == nil {
+		return sh.Shell.Cmd(script)
+	}
+	return res.Cmd()
+}
+
+// Pipe constructs a Pipeline whose script stages are mocked. When replaying a
+// Cassette, the whole pipeline is replayed as one recorded script instead,
+// and none of its stages run.
+func (sh *MockShell) Pipe(stages ...interface{}) *Pipeline {
+	if sh.Cassette == nil {
+		return sh.Shell.pipe(sh.Cmd, stages)
+	}
+	p := sh.Shell.pipe(sh.Shell.Cmd, stages)
+	p.replay = sh.popMock
+	return p
+}
+
+// @StaticCompose.Inside("formatters")
+func (sh *MockShell) Do(script string) *Result {
+	res := sh.popMock(script)
+	if res == nil {
+		return sh.Shell.Do(script)
+	}
+	return sh.result(res)
+}
+
 // @StaticCompose.Inside("formatters")
 func (sh *MockShell) Out(script string) string {
 	res := sh.popMock(script)
 	if res == nil {
 		return sh.Shell.Out(script)
 	}
-	return res.Stdout
+	return sh.result(res).Stdout
 }
 
 // @StaticCompose.Inside("formatters")
@@ -63,7 +143,7 @@ func (sh *MockShell) OutStatus(script string) (string, error) {
 	if res == nil {
 		return sh.Shell.OutStatus(script)
 	}
-	return res.Stdout, res.ExitError()
+	return res.Stdout, sh.result(res).Err()
 }
 
 // @StaticCompose.Inside("formatters")
@@ -72,7 +152,7 @@ func (sh *MockShell) OutErrStatus(script string) (string, string, error) {
 	if res == nil {
 		return sh.Shell.OutErrStatus(script)
 	}
-	return res.Stdout, res.Stderr, res.ExitError()
+	return res.Stdout, res.Stderr, sh.result(res).Err()
 }
 
 // @StaticCompose.Inside("formatters")
@@ -81,7 +161,8 @@ func (sh *MockShell) Run(script string) error {
 	if res == nil {
 		return sh.Shell.Run(script)
 	}
-	return res.ExitError()
+	res.write(sh.Stdout, sh.Stderr)
+	return sh.result(res).Err()
 }
 
 // @StaticCompose.Inside("formatters")
@@ -90,27 +171,343 @@ func (sh *MockShell) Succeeds(script string) bool {
 	if res == nil {
 		return sh.Shell.Succeeds(script)
 	}
-	return res.ExitError() == nil
+	return sh.result(res).Success()
 }
 
-func (sh *MockShell) popMock(script string) *MockCall {
-	mocks, found := sh.Mocks[script]
-	if !found || len(mocks) == 0 {
-		if sh.AllowUnmocked {
-			return nil
+// @StaticCompose.Inside("formatters")
+func (sh *MockShell) Lines(script string, fn func(line string) error) error {
+	res := sh.popMock(script)
+	if res == nil {
+		return sh.Shell.Lines(script, fn)
+	}
+	result := sh.result(res)
+	for _, line := range result.Lines() {
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	return result.Err()
+}
+
+// @StaticCompose.Inside("formatters")
+func (sh *MockShell) Stream(script string) (<-chan Line, *Result) {
+	res := sh.popMock(script)
+	if res == nil {
+		return sh.Shell.Stream(script)
+	}
+	return sh.streamChan(func(fn func(Line) error) *Result {
+		result := res.Result()
+		stopped := false
+		for _, line := range result.Lines() {
+			if err := fn(Line{line, 1, result.Start}); err != nil {
+				stopped = true
+				break
+			}
+		}
+		for _, line := range (&Result{Stdout: result.Stderr}).Lines() {
+			if err := fn(Line{line, 2, result.Start}); err != nil {
+				stopped = true
+				break
+			}
+		}
+		if !stopped {
+			sh.onErrorLater(result)
 		}
+		return result
+	})
+}
+
+// @StaticCompose.Inside("formatters")
+func (sh *MockShell) OutJSON(script string, v interface{}) error {
+	res := sh.popMock(script)
+	if res == nil {
+		return sh.Shell.OutJSON(script, v)
+	}
+	result := sh.result(res)
+	return sh.decoded(result, func() error { return result.JSON(v) })
+}
+
+// @StaticCompose.Inside("formatters")
+func (sh *MockShell) OutLines(script string) []string {
+	res := sh.popMock(script)
+	if res == nil {
+		return sh.Shell.OutLines(script)
+	}
+	return sh.result(res).Lines()
+}
+
+// @StaticCompose.Inside("formatters")
+func (sh *MockShell) OutFields(script string, sep string) [][]string {
+	res := sh.popMock(script)
+	if res == nil {
+		return sh.Shell.OutFields(script, sep)
+	}
+	return sh.result(res).Fields(sep)
+}
+
+// @StaticCompose.Inside("formatters")
+func (sh *MockShell) OutCSV(script string) ([][]string, error) {
+	res := sh.popMock(script)
+	if res == nil {
+		return sh.Shell.OutCSV(script)
+	}
+	result := sh.result(res)
+	var records [][]string
+	err := sh.decoded(result, func() (err error) {
+		records, err = result.CSV()
+		return err
+	})
+	return records, err
+}
+
+// Start runs the mock for script in the background, using a mock process. See
+// MockCall.Cmd.
+//
+// @StaticCompose.Inside("formatters")
+func (sh *MockShell) Start(script string) *Job {
+	return sh.Shell.startJob(sh.Cmd(script), script)
+}
+
+// Parallel runs the mocks for each script. Like Shell.Parallel, the output of
+// each script is prefixed with its label.
+func (sh *MockShell) Parallel(n int, scripts ...string) error {
+	return sh.Shell.forEach(scripts, n, scriptLabel, func(job *Shell, script string) error {
+		return sh.forJob(job).Run(script)
+	})
+}
+
+// ForEach calls fn for each item with a copy of the mock shell that prefixes
+// the output of scripts with the item, like Shell.ForEach. The copies share
+// the mocks and their progress with sh.
+func (sh *MockShell) ForEach(items []string, n int, fn func(sh Interface, item string) error) error {
+	return sh.Shell.forEach(items, n, nil, func(job *Shell, item string) error {
+		return fn(sh.forJob(job), item)
+	})
+}
+
+// forJob returns a copy of the mock shell with the settings of job, a shell
+// made by forEach for one item.
+func (sh *MockShell) forJob(job *Shell) *MockShell {
+	copied := sh.derive()
+	copied.Shell = *job
+	return copied
+}
 
-		panic(fmt.Errorf("No mocks configured for script: %s", script))
+// ExecCmd returns a command that emulates the mock for argv when run. See
+// MockCall.Cmd.
+//
+// @StaticCompose.Inside("argv")
+func (sh *MockShell) ExecCmd(argv ...string) *exec.Cmd {
+	res := sh.popMock(argvScript(argv))
+	if res == nil {
+		return sh.Shell.ExecCmd(argv...)
 	}
+	return res.Cmd()
+}
 
-	index := sh.mockProgress[script]
-	if sh.LoopMocks {
+// @StaticCompose.Inside("argv")
+func (sh *MockShell) ExecDo(argv ...string) *Result {
+	script := argvScript(argv)
+	res := sh.popMock(script)
+	if res == nil {
+		return sh.Shell.ExecDo(argv...)
+	}
+	return sh.result(res)
+}
+
+// @StaticCompose.Inside("argv")
+func (sh *MockShell) Exec(argv ...string) error {
+	res := sh.popMock(argvScript(argv))
+	if res == nil {
+		return sh.Shell.Exec(argv...)
+	}
+	res.write(sh.Stdout, sh.Stderr)
+	return sh.result(res).Err()
+}
+
+// @StaticCompose.Inside("argv")
+func (sh *MockShell) ExecOut(argv ...string) string {
+	res := sh.popMock(argvScript(argv))
+	if res == nil {
+		return sh.Shell.ExecOut(argv...)
+	}
+	return sh.result(res).Stdout
+}
+
+// @StaticCompose.Inside("argv")
+func (sh *MockShell) ExecOutStatus(argv ...string) (string, error) {
+	res := sh.popMock(argvScript(argv))
+	if res == nil {
+		return sh.Shell.ExecOutStatus(argv...)
+	}
+	return res.Stdout, sh.result(res).Err()
+}
+
+// @StaticCompose.Inside("argv")
+func (sh *MockShell) ExecOutErrStatus(argv ...string) (string, string, error) {
+	res := sh.popMock(argvScript(argv))
+	if res == nil {
+		return sh.Shell.ExecOutErrStatus(argv...)
+	}
+	return res.Stdout, res.Stderr, sh.result(res).Err()
+}
+
+// @StaticCompose.Inside("argv")
+func (sh *MockShell) ExecSucceeds(argv ...string) bool {
+	res := sh.popMock(argvScript(argv))
+	if res == nil {
+		return sh.Shell.ExecSucceeds(argv...)
+	}
+	return sh.result(res).Success()
+}
+
+// Replay configures the shell to serve scripts from a recorded Cassette, in
+// the order they were recorded. A script that does not match the next call in
+// the cassette panics with a diff.
+func (sh *MockShell) Replay(cassette *Cassette) *MockShell {
+	sh.Cassette = cassette
+	return sh
+}
+
+func (sh *MockShell) popMock(script string) *MockCall {
+	progress := sh.mockProgress()
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+	if sh.Cassette != nil {
+		call := sh.Cassette.pop(script, sh.Dir, sh.tracedEnv())
+		sh.receiveStdin(script, nil)
+		return call
+	}
+
+	mocks := sh.Mocks[script]
+	index := progress.mocks[script]
+	if sh.LoopMocks && len(mocks) > 0 {
 		index = index % len(mocks)
 	}
+	if index < len(mocks) {
+		mock := mocks[index]
+		progress.mocks[script] = index + 1
+		sh.receiveStdin(script, nil)
+		return &mock
+	}
 
-	mock := mocks[index]
-	sh.mockProgress[script] = index + 1
-	return &mock
+	if e := sh.popExpectation(script); e != nil {
+		sh.receiveStdin(script, e)
+		call := e.Call
+		call.Script = script
+		return &call
+	}
+	if sh.AllowUnmocked {
+		return nil
+	}
+	panic(sh.unmatched(script))
+}
+
+// receiveStdin reads the shell's Stdin on behalf of a mocked script, and
+// records it. If e expects different stdin, receiveStdin panics with a diff.
+// The caller must hold the progress lock.
+func (sh *MockShell) receiveStdin(script string, e *Expectation) {
+	if sh.Stdin == nil {
+		return
+	}
+	data, err := ioutil.ReadAll(sh.stdin())
+	if err != nil {
+		panic(fmt.Errorf("Could not read stdin for script %s: %v", script, err))
+	}
+	stdin := string(data)
+	if e == nil {
+		sh.progress.stdins[script] = append(sh.progress.stdins[script], stdin)
+		return
+	}
+
+	e.addStdin(stdin)
+	if e.stdin != nil && *e.stdin != stdin {
+		panic(fmt.Errorf("Unexpected stdin for script: %s\n%s", script, diffLines(*e.stdin, stdin)))
+	}
+}
+
+// Feed returns a copy of the mock shell that connects r to the Stdin of
+// scripts. The copy shares the mocks and their progress with sh. Mocked
+// scripts read all of r; see Stdins and Expectation.WithStdin.
+func (sh *MockShell) Feed(r io.Reader) Interface {
+	copied := sh.derive()
+	copied.Stdin = r
+	return copied
+}
+
+// With returns a copy of the mock shell with the given options applied. The
+// copy shares the mocks and their progress with sh. See Shell.With.
+func (sh *MockShell) With(opts ...Option) Interface {
+	copied := sh.derive()
+	copied.Shell = *sh.Shell.with(opts...)
+	return copied
+}
+
+// Must returns a copy of the mock shell that panics if a script fails, mocked
+// or not. See Shell.Must.
+func (sh *MockShell) Must() Interface {
+	return sh.With(Must)
+}
+
+// WithDir returns a copy of the mock shell that runs unmocked scripts in dir.
+// The copy shares the mocks and their progress with sh. See Shell.WithDir.
+func (sh *MockShell) WithDir(dir string) Interface {
+	copied := sh.derive()
+	copied.Shell = *sh.Shell.Copy()
+	Dir(dir)(&copied.Shell)
+	return copied
+}
+
+// InDir calls fn with a copy of the mock shell that runs unmocked scripts in
+// dir. See Shell.InDir.
+func (sh *MockShell) InDir(dir string, fn func(sh Interface)) {
+	fn(sh.WithDir(dir))
+}
+
+// WithEnv returns a copy of the mock shell that adds vars to the environment
+// of unmocked scripts. The copy shares the mocks and their progress with sh.
+// See Shell.WithEnv.
+func (sh *MockShell) WithEnv(vars Environer) Interface {
+	copied := sh.derive()
+	copied.Shell = *sh.Shell.Copy()
+	Env(vars)(&copied.Shell)
+	return copied
+}
+
+// derive returns a copy of the mock shell that shares its mocks, their
+// progress, and the state of its Shell.
+func (sh *MockShell) derive() *MockShell {
+	sh.mockProgress()
+	sh.Shell.state()
+	mockProgresses.Lock()
+	copied := *sh
+	mockProgresses.Unlock()
+	return &copied
+}
+
+// FeedString is Feed with a string. See Shell.FeedString.
+func (sh *MockShell) FeedString(input string) Interface {
+	return sh.Feed(newFedInput([]byte(input)))
+}
+
+// FeedJSON is Feed with a JSON-encoded value. See Shell.FeedJSON.
+func (sh *MockShell) FeedJSON(v interface{}) Interface {
+	return sh.Feed(jsonReader(v))
+}
+
+// @StaticCompose.Inside("formatters")
+func (sh *MockShell) OutIn(script string, input string) string {
+	return sh.FeedString(input).Out(script)
+}
+
+// Stdins returns the stdin received by each call of a mocked script that was
+// not matched by an Expectation, in order. Scripts only receive stdin if the
+// shell has one; see Feed.
+func (sh *MockShell) Stdins(script string) []string {
+	progress := sh.mockProgress()
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+	return append([]string(nil), progress.stdins[script]...)
 }
 
 // MockCall describes an expected script that will return the mocked version, instead.
@@ -121,14 +518,66 @@ type MockCall struct {
 	Stderr     string
 }
 
-// ExitError returns the *exec.ExitError for this mock call's ExitStatus, or
-// nil if the ExitStatus is zero.
-func (call MockCall) ExitError() *exec.ExitError {
+// Result returns a Result for this mock call, as if the script had run
+// instantly.
+func (call MockCall) Result() *Result {
+	now := time.Now()
+	res := &Result{
+		Script:   call.Script,
+		Stdout:   call.Stdout,
+		Stderr:   call.Stderr,
+		Combined: call.combined(),
+		ExitCode: call.ExitStatus,
+		Attempts: 1,
+		Start:    now,
+		End:      now,
+	}
+	if err := call.ExitError(); err != nil {
+		res.err = err
+	}
+	return res
+}
+
+// combined returns the Stdout and Stderr of the call on separate lines, as if
+// the script wrote Stdout first.
+func (call MockCall) combined() string {
+	var parts []string
+	for _, out := range []string{call.Stdout, call.Stderr} {
+		if out != "" {
+			parts = append(parts, out)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// result returns the Result of a mocked call, and handles its error like the
+// shell handles the error of a script that really ran. See Shell.ErrorPolicy.
+func (sh *MockShell) result(call *MockCall) *Result {
+	res := call.Result()
+	sh.onError(res.err)
+	return res
+}
+
+// write writes the Stdout and Stderr of the call to stdout and stderr, each
+// ending in a newline like a script's output. Nil writers are skipped.
+func (call MockCall) write(stdout, stderr io.Writer) {
+	if call.Stdout != "" && stdout != nil {
+		io.WriteString(stdout, call.Stdout+"\n")
+	}
+	if call.Stderr != "" && stderr != nil {
+		io.WriteString(stderr, call.Stderr+"\n")
+	}
+}
+
+// ExitError returns the *ExitError for this mock call's Script, ExitStatus
+// and Stderr, or nil if the ExitStatus is zero.
+func (call MockCall) ExitError() *ExitError {
 	if call.ExitStatus == 0 {
 		return nil
 	}
-
-	// uhh this is hard to mock because of os.ProcessState so just build one
-	err := exec.Command("sh", "-c", fmt.Sprintf("exit %d", call.ExitStatus)).Run()
-	return err.(*exec.ExitError)
+	return &ExitError{
+		Script:     call.Script,
+		Code:       call.ExitStatus,
+		StderrTail: lastLines(call.Stderr, stderrTailLines),
+	}
 }
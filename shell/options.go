@@ -0,0 +1,118 @@
+package shell
+
+// Immutable per-call configuration, so one Shell can be shared between
+// goroutines.
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Option configures a shell derived with With. Options only modify the
+// derived shell, never the shell they were derived from.
+type Option func(sh *Shell)
+
+// Must is an Option that makes the derived shell panic if a script fails, by
+// setting its ErrorPolicy to PanicOnError.
+//
+//   sh.With(shell.Must).Run(`make`)
+var Must Option = func(sh *Shell) {
+	sh.ErrorPolicy = PanicOnError
+}
+
+// Timeout is an Option that terminates each script run by the derived shell
+// if it is still running after d. The script and the processes it started are
+// sent SIGTERM, and then SIGKILL if they are still running after the
+// GracePeriod.
+func Timeout(d time.Duration) Option {
+	return func(sh *Shell) {
+		sh.timeout = d
+	}
+}
+
+// Context is an Option that kills scripts run by the derived shell once ctx
+// is done.
+func Context(ctx context.Context) Option {
+	return func(sh *Shell) {
+		sh.ctx = ctx
+	}
+}
+
+// Dir is an Option that runs scripts in dir. A relative dir is resolved
+// against the shell's current Dir. See WithDir.
+func Dir(dir string) Option {
+	return func(sh *Shell) {
+		sh.Dir = resolveDir(sh.Dir, dir)
+	}
+}
+
+// Env is an Option that adds vars to the environment of scripts. See WithEnv.
+func Env(vars Environer) Option {
+	return func(sh *Shell) {
+		if sh.Env == nil {
+			sh.Env = vars
+		} else {
+			sh.Env = layeredEnv{sh.Env, vars}
+		}
+	}
+}
+
+// With returns a copy of the shell with the given options applied. The options
+// don't modify sh, and the state sh shares with the copy is allocated under a
+// lock the first time it's needed, so it is safe to share one Shell between
+// goroutines and derive a shell for each call:
+//
+//   sh := &shell.Shell{Stderr: os.Stderr}
+//   for _, host := range hosts {
+//     go sh.With(shell.Must, shell.Timeout(time.Minute)).Runf(`ssh %s uptime`, host)
+//   }
+//
+// The derived shell shares LastError and background jobs with sh, so code that
+// checks sh.LastError() after sh.With(...).Succeeds(...) keeps working.
+func (sh *Shell) With(opts ...Option) Interface {
+	return sh.with(opts...)
+}
+
+// with is With, returning the derived *Shell.
+func (sh *Shell) with(opts ...Option) *Shell {
+	sh.state()
+	sharedStates.Lock()
+	copied := *sh
+	sharedStates.Unlock()
+
+	for _, opt := range opts {
+		opt(&copied)
+	}
+	return &copied
+}
+
+// sharedStates guards the shared field of every Shell, which is allocated
+// lazily so that the zero Shell is ready to use.
+var sharedStates sync.Mutex
+
+// sharedState is shared by a shell and the shells derived from it with With.
+type sharedState struct {
+	mu        sync.Mutex
+	lastError *ExitError
+	jobs      []*Job
+	// Also shared with shells derived by Copy.
+	secrets *secretSet
+}
+
+// state returns the shell's shared state, allocating it if needed.
+func (sh *Shell) state() *sharedState {
+	sharedStates.Lock()
+	defer sharedStates.Unlock()
+	if sh.shared == nil {
+		sh.shared = &sharedState{secrets: &secretSet{}}
+	}
+	return sh.shared
+}
+
+func (sh *Shell) setLastError(err *ExitError) {
+	state := sh.state()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.lastError = err
+}
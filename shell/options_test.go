@@ -0,0 +1,76 @@
+package shell
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWith(t *testing.T) {
+	sh := &Shell{}
+	must := sh.with(Must)
+	if sh.ErrorPolicy != nil || must.ErrorPolicy == nil {
+		t.Errorf("With(Must) should only modify the derived shell")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("With(Must).Run(`exit 1`) should panic")
+			}
+		}()
+		must.Run(`exit 1`)
+	}()
+	if sh.LastError() == nil || sh.LastError().ExitCode() != 1 {
+		t.Errorf("LastError() -> %v, expected it to be shared with derived shell", sh.LastError())
+	}
+	if err := sh.Run(`exit 2`); err == nil {
+		t.Errorf("Run(`exit 2`) should not panic after With(Must)")
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	sh := (&Shell{}).With(Timeout(50 * time.Millisecond))
+	start := time.Now()
+	res := sh.Do(`sleep 5`)
+	if res.Success() || time.Since(start) > 2*time.Second {
+		t.Errorf("Do(`sleep 5`) with Timeout -> %v after %v", res.Err(), time.Since(start))
+	}
+}
+
+func TestWithConcurrent(t *testing.T) {
+	sh := &Shell{}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			derived := sh.With(Env(Vars{"N": i}))
+			if out := derived.Out(`echo $N`); out != fmt.Sprint(i) {
+				t.Errorf("Out(`echo $N`) -> %q != %q", out, fmt.Sprint(i))
+			}
+			sh.Succeeds(`exit 1`)
+			sh.LastError()
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestMockShellWith(t *testing.T) {
+	sh := &MockShell{}
+	sh.AddMock(MockCall{Script: "hostname", Stdout: "mocked"})
+	sh.AddMock(MockCall{Script: "make", ExitStatus: 2})
+	if out := sh.With(Timeout(time.Minute)).Out("hostname"); out != "mocked" {
+		t.Errorf("With(...).Out(`hostname`) -> %q, expected the mock", out)
+	}
+
+	defer func() {
+		err, ok := recover().(*ExitError)
+		if !ok || err.ExitCode() != 2 {
+			t.Errorf("With(Must).Run(`make`) -> panic %v, expected the mocked ExitError", err)
+		}
+	}()
+	sh.With(Must).Run("make")
+	t.Errorf("With(Must).Run(`make`) should panic")
+}
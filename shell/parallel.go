@@ -0,0 +1,329 @@
+package shell
+
+// Run many scripts at once, with a concurrency limit.
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// FailFast is an Option that makes Parallel and ForEach cancel the remaining
+// scripts as soon as one fails, like an errgroup. Each script runs in its own
+// process group, so cancelling it also kills every process it started. See
+// ProcessGroup.
+//
+//   err := sh.With(shell.FailFast).Parallel(4, `make lint`, `make test`)
+var FailFast Option = func(sh *Shell) {
+	sh.failFast = true
+}
+
+// Parallel runs the scripts at the same time, with at most n running at once.
+// If n <= 0, all the scripts are started at once. Each line written by a
+// script to Stdout or Stderr is prefixed with a label like "[make test]".
+//
+// Parallel waits for every script, and returns a *ParallelError describing
+// each script that failed, or nil if they all succeeded. The *ParallelError is
+// also handled by the shell's ErrorPolicy, so with the Must option, Parallel
+// panics with it instead.
+//
+//   err := sh.Parallel(2, `make lint`, `make test`, `make docs`)
+func (sh *Shell) Parallel(n int, scripts ...string) error {
+	return sh.forEach(scripts, n, scriptLabel, func(job *Shell, script string) error {
+		return job.Run(script)
+	})
+}
+
+// ForEach calls fn for each item at the same time, with at most n calls
+// running at once. If n <= 0, all the calls are started at once. Each call
+// gets a shell that prefixes the lines its scripts write with "[item]".
+//
+// If fn returns an error or panics with one, for example because it used
+// Must, the item is recorded as a failure. ForEach waits for every call, and
+// returns a *ParallelError describing each failure, or nil if there were none.
+//
+//   err := sh.ForEach(hosts, 8, func(sh Interface, host string) error {
+//     return sh.Runf(`ssh %s sudo apt-get upgrade -y`, host)
+//   })
+func (sh *Shell) ForEach(items []string, n int, fn func(sh Interface, item string) error) error {
+	return sh.forEach(items, n, nil, func(job *Shell, item string) error {
+		return fn(job, item)
+	})
+}
+
+// ParallelError lists the failures of a Parallel or ForEach call.
+type ParallelError struct {
+	// Failures in the order they happened.
+	Failures []*ParallelFailure
+	// Number of scripts or items.
+	Total int
+}
+
+// ParallelFailure describes one failed script or item.
+type ParallelFailure struct {
+	// Label that prefixed the output of the script or item.
+	Label string
+	// The last script that failed while running the item, if any.
+	Script string
+	// Returned by the script or fn.
+	Err error
+	// The last few lines of Stderr of the failed script.
+	StderrTail string
+}
+
+// Error lists each failure and the tail of its Stderr.
+func (e *ParallelError) Error() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d of %d failed:", len(e.Failures), e.Total)
+	for _, f := range e.Failures {
+		fmt.Fprintf(&buf, "\n[%s]", f.Label)
+		if f.Script != "" {
+			fmt.Fprintf(&buf, " %s:", f.Script)
+		}
+		fmt.Fprintf(&buf, " %s", briefError(f.Err))
+		for _, line := range strings.Split(f.StderrTail, "\n") {
+			if line != "" {
+				fmt.Fprintf(&buf, "\n    %s", line)
+			}
+		}
+	}
+	return buf.String()
+}
+
+// Number of lines of Stderr kept in a ParallelFailure.
+const stderrTailLines = 5
+
+// forEach implements ForEach and Parallel. If label is nil, items are their
+// own labels.
+func (sh *Shell) forEach(items []string, n int, label func(item string) string, fn func(job *Shell, item string) error) error {
+	if label == nil {
+		label = func(item string) string { return item }
+	}
+	if n <= 0 || n > len(items) {
+		n = len(items)
+	}
+
+	ctx := sh.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		output = new(sync.Mutex)
+		mu     sync.Mutex
+		failed = &ParallelError{Total: len(items)}
+		wg     sync.WaitGroup
+		tokens = make(chan struct{}, n)
+	)
+	fail := func(f *ParallelFailure) {
+		mu.Lock()
+		defer mu.Unlock()
+		if sh.failFast && len(failed.Failures) > 0 {
+			// Killed by cancel, after the failure that matters.
+			return
+		}
+		failed.Failures = append(failed.Failures, f)
+		if sh.failFast {
+			cancel()
+		}
+	}
+
+	for _, item := range items {
+		tokens <- struct{}{}
+		if ctx.Err() != nil {
+			<-tokens
+			break
+		}
+		wg.Add(1)
+		go func(item string) {
+			defer wg.Done()
+			defer func() { <-tokens }()
+			job := newParallelJob(sh, ctx, label(item), output)
+			err := job.run(fn, item)
+			job.flush()
+			if err != nil {
+				fail(job.failure(err))
+			}
+		}(item)
+	}
+	wg.Wait()
+
+	if len(failed.Failures) == 0 {
+		return nil
+	}
+	sh.errorPolicy().HandleError(failed)
+	return failed
+}
+
+// parallelJob is the state of one item in a forEach.
+type parallelJob struct {
+	sh     *Shell
+	label  string
+	stdout *prefixWriter
+	stderr *prefixWriter
+
+	mu           sync.Mutex
+	failedScript string
+	failedStderr string
+}
+
+func newParallelJob(parent *Shell, ctx context.Context, label string, output *sync.Mutex) *parallelJob {
+	job := &parallelJob{label: label}
+	prefix := fmt.Sprintf("[%s] ", label)
+	job.stdout = &prefixWriter{mu: output, w: parent.Stdout, prefix: prefix}
+	job.stderr = &prefixWriter{mu: output, w: parent.Stderr, prefix: prefix, tail: stderrTailLines}
+
+	job.sh = parent.with(Context(ctx))
+	if parent.failFast {
+		// Context only kills the script itself, not its children.
+		job.sh.processGroup = true
+	}
+	job.sh.ErrorPolicy = ReturnErrors
+	job.sh.failFast = false
+	job.sh.Stdout = job.stdout
+	job.sh.Stderr = job.stderr
+	job.sh.Trace = TraceOutput(TracerFunc(func(event TraceEvent) {
+		if parent.Trace != nil {
+			sendTrace(parent.Trace, event)
+		}
+		if event.Kind == TraceStart {
+			// Only the failing script's stderr belongs in the failure.
+			job.stderr.resetTail()
+		}
+		if event.Kind == TraceFinish && event.ExitCode != 0 {
+			job.mu.Lock()
+			job.failedScript = event.Script
+			job.failedStderr = event.Stderr
+			job.mu.Unlock()
+		}
+	}))
+	return job
+}
+
+// run calls fn, and turns a panic with an error into a returned error.
+func (job *parallelJob) run(fn func(job *Shell, item string) error, item string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicErr, ok := r.(error)
+			if !ok {
+				panic(r)
+			}
+			err = panicErr
+		}
+	}()
+	return fn(job.sh, item)
+}
+
+func (job *parallelJob) flush() {
+	job.stdout.flush()
+	job.stderr.flush()
+}
+
+func (job *parallelJob) failure(err error) *ParallelFailure {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	script := job.failedScript
+	tail := lastLines(job.failedStderr, stderrTailLines)
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		if script == "" {
+			script = exitErr.Script
+		}
+		if exitErr.StderrTail != "" {
+			tail = exitErr.StderrTail
+		}
+	}
+	if tail == "" {
+		tail = job.stderr.lastLines()
+	}
+	return &ParallelFailure{
+		Label:      job.label,
+		Script:     script,
+		Err:        err,
+		StderrTail: tail,
+	}
+}
+
+// prefixWriter writes each complete line to w with a prefix, holding mu so
+// lines from different jobs are not interleaved. If tail is set, the last tail
+// lines are remembered. A nil w discards the lines.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	tail   int
+
+	partial []byte
+	lines   []string
+}
+
+func (pw *prefixWriter) Write(p []byte) (int, error) {
+	pw.partial = append(pw.partial, p...)
+	for {
+		i := bytes.IndexByte(pw.partial, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		pw.writeLine(string(pw.partial[:i]))
+		pw.partial = pw.partial[i+1:]
+	}
+}
+
+// flush writes any unterminated last line.
+func (pw *prefixWriter) flush() {
+	if len(pw.partial) > 0 {
+		pw.writeLine(string(pw.partial))
+		pw.partial = nil
+	}
+}
+
+func (pw *prefixWriter) writeLine(line string) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if pw.tail > 0 {
+		pw.lines = append(pw.lines, line)
+		if len(pw.lines) > pw.tail {
+			pw.lines = pw.lines[1:]
+		}
+	}
+	if pw.w != nil {
+		fmt.Fprintf(pw.w, "%s%s\n", pw.prefix, line)
+	}
+}
+
+func (pw *prefixWriter) lastLines() string {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	return strings.Join(pw.lines, "\n")
+}
+
+// resetTail forgets the remembered lines.
+func (pw *prefixWriter) resetTail() {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	pw.lines = nil
+}
+
+// lastLines returns the last n lines of s.
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// scriptLabel labels a script by its first line, shortened if needed.
+func scriptLabel(script string) string {
+	label := strings.TrimSpace(strings.SplitN(strings.TrimSpace(script), "\n", 2)[0])
+	if len(label) > 32 {
+		label = label[:29] + "..."
+	}
+	return label
+}
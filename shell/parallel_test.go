@@ -0,0 +1,138 @@
+package shell
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParallel(t *testing.T) {
+	var out bytes.Buffer
+	sh := &Shell{Stdout: &out, Stderr: &out}
+	err := sh.Parallel(2, `echo a; echo b`, `echo c >&2`, `printf d`)
+	if err != nil {
+		t.Fatalf("Parallel(...) -> %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	sort.Strings(lines)
+	expected := []string{"[echo a; echo b] a", "[echo a; echo b] b", "[echo c >&2] c", "[printf d] d"}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Parallel(...) output -> %q != %q", lines, expected)
+	}
+}
+
+func TestParallelError(t *testing.T) {
+	sh := &Shell{}
+	err := sh.Parallel(0, `true`, `echo one >&2; echo two >&2; exit 3`, `exit 4`)
+	perr, ok := err.(*ParallelError)
+	if !ok || len(perr.Failures) != 2 || perr.Total != 3 {
+		t.Fatalf("Parallel(...) -> %#v, expected 2 of 3 failures", err)
+	}
+	msg := perr.Error()
+	for _, s := range []string{"2 of 3 failed", "exit status 3", "    one\n    two", "[exit 4]"} {
+		if !strings.Contains(msg, s) {
+			t.Errorf("ParallelError.Error() -> %q does not contain %q", msg, s)
+		}
+	}
+}
+
+func TestParallelFailFast(t *testing.T) {
+	sh := (&Shell{}).With(FailFast)
+	start := time.Now()
+	err := sh.Parallel(2, `exit 1`, `sleep 5`, `sleep 5`)
+	if err == nil || len(err.(*ParallelError).Failures) != 1 {
+		t.Errorf("Parallel(...) with FailFast -> %v, expected one failure", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("Parallel(...) with FailFast did not cancel sleeps")
+	}
+}
+
+func TestParallelFailFastKillsChildren(t *testing.T) {
+	sh := (&Shell{}).With(FailFast)
+	start := time.Now()
+	// The children of the second script hold Stdout open, so Parallel only
+	// returns once they are killed too.
+	err := sh.Parallel(2, `sleep 0.1; exit 1`, `sleep 5 & sleep 5 & wait`)
+	if err == nil || time.Since(start) > 2*time.Second {
+		t.Errorf("Parallel(...) with FailFast -> %v after %v, expected children to be killed", err, time.Since(start))
+	}
+}
+
+func TestMockParallelLabels(t *testing.T) {
+	var out bytes.Buffer
+	sh := &MockShell{Shell: Shell{Stdout: &out, Stderr: &out}}
+	sh.AddMock(MockCall{Script: "make lint", Stdout: "ok"})
+	sh.AddMock(MockCall{Script: "make test", ExitStatus: 2, Stderr: "FAIL"})
+	err := sh.Parallel(1, "make lint", "make test")
+	expected := "[make lint] ok\n[make test] FAIL\n"
+	if out.String() != expected {
+		t.Errorf("Parallel(...) output -> %q != %q", out.String(), expected)
+	}
+	perr, ok := err.(*ParallelError)
+	if !ok || len(perr.Failures) != 1 || perr.Failures[0].StderrTail != "FAIL" {
+		t.Errorf("Parallel(...) -> %v, expected make test to fail", err)
+	}
+
+	out.Reset()
+	sh.AddMock(MockCall{Script: "ping a", Stdout: "pong"})
+	sh.ForEach([]string{"a"}, 0, func(sh Interface, host string) error {
+		return sh.Runf(`ping %s`, host)
+	})
+	if out.String() != "[a] pong\n" {
+		t.Errorf("ForEach(...) output -> %q", out.String())
+	}
+}
+
+func TestForEachLimit(t *testing.T) {
+	var mu sync.Mutex
+	running, most := 0, 0
+	err := (&Shell{}).ForEach([]string{"a", "b", "c", "d", "e"}, 2, func(sh Interface, item string) error {
+		mu.Lock()
+		running++
+		if running > most {
+			most = running
+		}
+		mu.Unlock()
+		sh.Must().Run(`sleep 0.05`)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	})
+	if err != nil || most != 2 {
+		t.Errorf("ForEach(..., 2, ...) -> %v with %d running at once", err, most)
+	}
+}
+
+func TestForEachMust(t *testing.T) {
+	sh := &MockShell{}
+	sh.AddMock(MockCall{Script: "ping a", ExitStatus: 0})
+	sh.AddMock(MockCall{Script: "ping b", ExitStatus: 2, Stderr: "unreachable"})
+	err := sh.ForEach([]string{"a", "b"}, 0, func(sh Interface, host string) error {
+		sh.Must().Runf(`ping %s`, host)
+		return nil
+	})
+	perr, ok := err.(*ParallelError)
+	if !ok || len(perr.Failures) != 1 || perr.Failures[0].Label != "b" || perr.Failures[0].StderrTail != "unreachable" {
+		t.Errorf("ForEach(...) -> %v, expected failure of b", err)
+	}
+}
+
+func TestForEachStderrTail(t *testing.T) {
+	sh := &Shell{}
+	err := sh.ForEach([]string{"a"}, 0, func(sh Interface, item string) error {
+		if err := sh.Run(`echo earlier >&2`); err != nil {
+			return err
+		}
+		return sh.Run(`echo failed >&2; exit 1`)
+	})
+	perr, ok := err.(*ParallelError)
+	if !ok || len(perr.Failures) != 1 || perr.Failures[0].StderrTail != "failed" {
+		t.Errorf("ForEach(...) -> %#v, expected only the failing script's stderr", err)
+	}
+}
@@ -0,0 +1,357 @@
+package shell
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Filter is a pipeline stage implemented in Go. A Filter should read its input
+// from in until EOF, and write its output to out. Returning a non-nil error
+// marks the stage as failed, like a non-zero exit status.
+type Filter func(in io.Reader, out io.Writer) error
+
+// LineFilter returns a Filter that calls fn for each line of its input. If
+// keep is true, the returned line is written to the output followed by a
+// newline. This is a typed replacement for the common `grep | sed` dance.
+//
+//   upper := LineFilter(func(line string) (string, bool) {
+//     return strings.ToUpper(line), strings.HasPrefix(line, "pod/")
+//   })
+func LineFilter(fn func(line string) (out string, keep bool)) Filter {
+	return func(in io.Reader, out io.Writer) error {
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			line, keep := fn(scanner.Text())
+			if !keep {
+				continue
+			}
+			if _, err := io.WriteString(out, line+"\n"); err != nil {
+				return err
+			}
+		}
+		return scanner.Err()
+	}
+}
+
+// FilterError is returned when a Filter stage of a Pipeline returns an error.
+type FilterError struct {
+	// Index of the failed stage in the pipeline.
+	Stage int
+	Err   error
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("pipeline stage %d: %v", e.Stage, e.Err)
+}
+
+// Pipeline connects the Stdout of each of its stages to the Stdin of the next,
+// like `a | b | c` in Bash. Stages may be shell scripts, *exec.Cmds, or Go
+// Filters. All stages run concurrently, so data streams through the pipeline
+// without being buffered in full.
+//
+// Construct a Pipeline with Shell.Pipe. A Pipeline may only be run once.
+type Pipeline struct {
+	sh       *Shell
+	stages   []stage
+	pipefail bool
+	statuses []int
+	errors   []error
+	// If set, returns the recorded call that replaces running the pipeline.
+	replay func(script string) *MockCall
+}
+
+type stage interface {
+	run(in io.Reader, out io.Writer) error
+	String() string
+}
+
+type cmdStage struct {
+	sh     *Shell
+	cmd    *exec.Cmd
+	script string
+}
+
+func (s cmdStage) String() string {
+	return s.script
+}
+
+func (s cmdStage) run(in io.Reader, out io.Writer) error {
+	s.cmd.Stdin = in
+	s.cmd.Stdout = out
+	if s.sh.dryRun(s.script) {
+		removeScriptFile(s.cmd)
+		return s.sh.DryRun.fake(s.cmd, s.script, s.sh.maskSecrets)
+	}
+	_, err := s.sh.runCmd(s.cmd)
+	if err == io.ErrClosedPipe && s.cmd.ProcessState != nil && s.cmd.ProcessState.Success() {
+		// The next stage stopped reading before we finished writing, but the
+		// command didn't notice.
+		return nil
+	}
+	return wrapExitError(err, s.script, s.cmd.Dir, "")
+}
+
+type filterStage struct {
+	index  int
+	filter Filter
+}
+
+func (s filterStage) String() string {
+	return "<filter>"
+}
+
+func (s filterStage) run(in io.Reader, out io.Writer) error {
+	if in == nil {
+		in = strings.NewReader("")
+	}
+	if err := s.filter(in, out); err != nil {
+		return &FilterError{s.index, err}
+	}
+	return nil
+}
+
+// Pipe constructs a Pipeline from the given stages. Each stage may be:
+//
+//   - a string, which is run as a script with sh.Cmd
+//   - an *exec.Cmd, whose Stdin and Stdout will be overwritten
+//   - a Filter, or a func(io.Reader, io.Writer) error
+//
+// Pipe panics if given any other kind of stage, or a string stage when the
+// shell's Interpreter uses ScriptStdin.
+//
+//   pods := sh.Pipe(`kubectl get pods -o name`, LineFilter(isReady), `head -1`).Out()
+func (sh *Shell) Pipe(stages ...interface{}) *Pipeline {
+	return sh.pipe(sh.Cmd, stages)
+}
+
+// pipe constructs a Pipeline, using makeCmd for script stages.
+func (sh *Shell) pipe(makeCmd func(script string) *exec.Cmd, stages []interface{}) *Pipeline {
+	p := &Pipeline{sh: sh}
+	interp := sh.interpreter()
+	for i, s := range stages {
+		switch s := s.(type) {
+		case string:
+			if sh.MakeCmd == nil && interp.Invoke == ScriptStdin && interp.Run == nil {
+				panic(fmt.Errorf("Pipe: stage %d can't be piped, because the Interpreter reads the script from Stdin", i))
+			}
+			cmd := makeCmd(s)
+			cmd.Stderr = sh.Stderr
+			p.stages = append(p.stages, cmdStage{sh, cmd, s})
+		case *exec.Cmd:
+			if s.Stderr == nil {
+				s.Stderr = sh.Stderr
+			}
+			p.stages = append(p.stages, cmdStage{sh, s, argvScript(s.Args)})
+		case Filter:
+			p.stages = append(p.stages, filterStage{i, s})
+		case func(io.Reader, io.Writer) error:
+			p.stages = append(p.stages, filterStage{i, Filter(s)})
+		default:
+			panic(fmt.Errorf("Pipe: stage %d has unsupported type %T", i, s))
+		}
+	}
+	return p
+}
+
+// Pipefail configures the pipeline to fail if any stage fails, like `set -o
+// pipefail` in Bash. The pipeline's error will be the error of the rightmost
+// failed stage. By default, only the status of the last stage is considered.
+func (p *Pipeline) Pipefail() *Pipeline {
+	p.pipefail = true
+	return p
+}
+
+// Out runs the pipeline and returns the Stdout of the last stage as a string,
+// minus the last trailing newline.
+func (p *Pipeline) Out() string {
+	out, _ := p.OutStatus()
+	return out
+}
+
+// OutStatus runs the pipeline and returns the Stdout of the last stage as a
+// string, minus the last trailing newline. If the pipeline fails, a non-nil
+// error is returned.
+func (p *Pipeline) OutStatus() (string, error) {
+	return p.run(nil)
+}
+
+// Run runs the pipeline to completion. The last stage's Stdout is connected to
+// the Shell's Stdout.
+func (p *Pipeline) Run() error {
+	stdout := p.sh.Stdout
+	if stdout == nil {
+		stdout = ioutil.Discard
+	}
+	_, err := p.run(stdout)
+	return err
+}
+
+// Succeeds runs the pipeline and returns true if it did not fail.
+func (p *Pipeline) Succeeds() bool {
+	return p.Run() == nil
+}
+
+// PipeStatus returns the exit status of each stage of a pipeline that has been
+// run, like $PIPESTATUS in Bash. Filter stages have status 1 if they returned
+// an error. Stages killed by a signal have status 128 plus the signal number,
+// like 141 for SIGPIPE, as in Bash.
+func (p *Pipeline) PipeStatus() []int {
+	return p.statuses
+}
+
+// Errors returns the error of each stage of a pipeline that has been run. The
+// error of a successful stage is nil.
+func (p *Pipeline) Errors() []error {
+	return p.errors
+}
+
+// run runs the pipeline, with the last stage's Stdout connected to stdout. If
+// stdout is nil, the output is captured and returned instead.
+func (p *Pipeline) run(stdout io.Writer) (string, error) {
+	if p.statuses != nil {
+		panic(fmt.Errorf("Pipeline already run"))
+	}
+
+	n := len(p.stages)
+	p.statuses = make([]int, n)
+	p.errors = make([]error, n)
+
+	scripts := make([]string, n)
+	for i, s := range p.stages {
+		scripts[i] = s.String()
+	}
+	script := strings.Join(scripts, " | ")
+	if p.replay != nil {
+		return p.replayed(p.replay(script), stdout)
+	}
+	var captured *bytes.Buffer
+	if stdout == nil {
+		captured = &bytes.Buffer{}
+		stdout = captured
+	}
+	res := &Result{Script: script, Start: time.Now(), Attempts: 1}
+	p.sh.trace(TraceStart, p.sh.Dir, res)
+
+	var wg sync.WaitGroup
+	var prev *io.PipeReader
+	for i, s := range p.stages {
+		in := p.sh.stdin()
+		if prev != nil {
+			in = prev
+		}
+		var out io.Writer = stdout
+		var next *io.PipeReader
+		var pw *io.PipeWriter
+		if i < n-1 {
+			next, pw = io.Pipe()
+			out = pw
+		}
+
+		wg.Add(1)
+		go func(i int, s stage, pr *io.PipeReader, in io.Reader, pw *io.PipeWriter, out io.Writer) {
+			defer wg.Done()
+			err := s.run(in, out)
+			// Signal EOF to the next stage, and a closed pipe to the previous stage,
+			// which is as close as we can get to SIGPIPE.
+			if pw != nil {
+				pw.Close()
+			}
+			if pr != nil {
+				pr.Close()
+			}
+			p.errors[i] = err
+			p.statuses[i] = pipeStatus(err)
+		}(i, s, prev, in, pw, out)
+
+		prev = next
+	}
+	wg.Wait()
+
+	err := p.err()
+	res.End = time.Now()
+	res.err = err
+	res.ExitCode = stageStatus(err)
+	if captured != nil {
+		res.Stdout = p.sh.trim(captured.Bytes())
+	}
+	p.sh.trace(TraceFinish, p.sh.Dir, res)
+
+	p.sh.onError(err)
+	return res.Stdout, err
+}
+
+// replayed finishes the pipeline with call, which was recorded for the whole
+// pipeline, instead of running its stages. Like run, it returns the output if
+// stdout is nil.
+func (p *Pipeline) replayed(call *MockCall, stdout io.Writer) (string, error) {
+	for _, s := range p.stages {
+		if s, ok := s.(cmdStage); ok {
+			removeScriptFile(s.cmd)
+		}
+	}
+	res := call.Result()
+	if last := len(p.stages) - 1; last >= 0 {
+		p.statuses[last] = res.ExitCode
+		p.errors[last] = res.err
+	}
+	call.write(stdout, p.sh.Stderr)
+	p.sh.onError(res.err)
+	return res.Stdout, res.err
+}
+
+func (p *Pipeline) err() error {
+	if len(p.errors) == 0 {
+		return nil
+	}
+	if !p.pipefail {
+		return p.errors[len(p.errors)-1]
+	}
+	for i := len(p.errors) - 1; i >= 0; i-- {
+		if p.errors[i] != nil {
+			return p.errors[i]
+		}
+	}
+	return nil
+}
+
+// pipeStatus returns the status of a stage for PipeStatus, which reports a
+// signal the way Bash does.
+func pipeStatus(err error) int {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		if sig, ok := exitErr.Signal.(syscall.Signal); ok {
+			return 128 + int(sig)
+		}
+	}
+	return stageStatus(err)
+}
+
+func stageStatus(err error) int {
+	var (
+		exitErr   *ExitError
+		execErr   *exec.ExitError
+		filterErr *FilterError
+	)
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr):
+		return exitErr.ExitCode()
+	case errors.As(err, &execErr):
+		return execErr.ExitCode()
+	case errors.As(err, &filterErr):
+		return 1
+	default:
+		// Couldn't start the command, like Bash's "command not found"
+		return 127
+	}
+}
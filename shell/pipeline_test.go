@@ -0,0 +1,75 @@
+package shell
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPipe(t *testing.T) {
+	sh := &Shell{}
+	upper := func(in io.Reader, out io.Writer) error {
+		data, err := ioutil.ReadAll(in)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(out, strings.ToUpper(string(data)))
+		return err
+	}
+	odd := LineFilter(func(line string) (string, bool) {
+		return line, strings.HasSuffix(line, "1") || strings.HasSuffix(line, "3")
+	})
+
+	out := sh.Pipe(`printf 'a1\nb2\nc3\n'`, odd, upper, `tr -d 13`).Out()
+	if out != "A\nC" {
+		t.Errorf("Pipe(...).Out() -> %q != %q", out, "A\nC")
+	}
+}
+
+func TestPipeStatus(t *testing.T) {
+	cases := []struct {
+		pipefail bool
+		stages   []interface{}
+		statuses []int
+		failed   bool
+	}{
+		{false, []interface{}{`exit 3`, `cat`}, []int{3, 0}, false},
+		{true, []interface{}{`exit 3`, `cat`}, []int{3, 0}, true},
+		{false, []interface{}{`echo hi`, `exit 2`}, []int{0, 2}, true},
+		// yes is killed by SIGPIPE when head exits.
+		{false, []interface{}{`yes`, `head -1`}, []int{141, 0}, false},
+		{true, []interface{}{
+			`echo hi`,
+			Filter(func(in io.Reader, out io.Writer) error { return errors.New("nope") }),
+			`cat`,
+		}, []int{0, 1, 0}, true},
+	}
+
+	for _, c := range cases {
+		p := (&Shell{}).Pipe(c.stages...)
+		if c.pipefail {
+			p.Pipefail()
+		}
+		err := p.Run()
+		statuses := p.PipeStatus()
+		if !reflect.DeepEqual(statuses, c.statuses) {
+			t.Errorf("Pipe(%#v).PipeStatus() -> %v != %v", c.stages, statuses, c.statuses)
+		}
+		if (err != nil) != c.failed {
+			t.Errorf("Pipe(%#v).Run() -> %v, expected failure: %v", c.stages, err, c.failed)
+		}
+	}
+}
+
+func TestPipeScriptStdin(t *testing.T) {
+	sh := (&Shell{}).with(Interp(&Interpreter{Args: []string{"sh"}, Invoke: ScriptStdin}))
+	defer func() {
+		if err, ok := recover().(error); !ok || !strings.Contains(err.Error(), "reads the script from Stdin") {
+			t.Errorf("Pipe(filter, `cat`) with ScriptStdin should panic, got %v", err)
+		}
+	}()
+	sh.Pipe(LineFilter(func(line string) (string, bool) { return line, true }), `cat`)
+}
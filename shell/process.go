@@ -0,0 +1,228 @@
+package shell
+
+// Process groups, so scripts and everything they start can be signalled and
+// cleaned up together.
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ProcessGroup is an Option that runs each script in its own process group.
+// While such a script runs, SIGINT and SIGTERM received by the program are
+// forwarded to the script's whole process group instead of killing the
+// program, so Ctrl-C stops the script and the method returns an error. When
+// the shell's context is done, every process in the group is killed, not
+// just the script. Use Cleanup to kill the groups of scripts that are still
+// running when the program exits.
+//
+// Scripts with a Timeout always run in their own process group.
+//
+// A script in its own process group is not in the terminal's foreground
+// process group, so it should not read from the terminal.
+//
+//   defer shell.Cleanup()
+//   sh := (&shell.Shell{}).With(shell.ProcessGroup)
+//   sh.Run(`./start-cluster.sh`)
+var ProcessGroup Option = func(sh *Shell) {
+	sh.processGroup = true
+}
+
+// Cleanup kills the process group of every script started in a process group
+// by any Shell that is still running, and removes any temporary script files.
+// Processes left behind by scripts that already exited are not killed: once a
+// script is reaped, its group ID may be reused by unrelated processes. Call it
+// before the program exits:
+//
+//   func main() {
+//     defer shell.Cleanup()
+//     ...
+//   }
+func Cleanup() {
+	removeScriptFiles()
+	groups.Lock()
+	defer groups.Unlock()
+	for p := range groups.all {
+		signalGroup(p, syscall.SIGKILL)
+	}
+}
+
+// groups tracks the process groups started by runCmd whose leader, which is
+// the script itself, has not been reaped. Only these groups are signalled,
+// because the ID of a group whose leader was reaped may belong to another
+// group by now.
+var groups = struct {
+	sync.Mutex
+	all     map[*os.Process]struct{}
+	signals chan os.Signal
+}{all: make(map[*os.Process]struct{})}
+
+// startGroup registers a process group whose leader is p. Signals are
+// forwarded to the group until finishGroup is called.
+func startGroup(p *os.Process) {
+	groups.Lock()
+	defer groups.Unlock()
+	groups.all[p] = struct{}{}
+	if len(groups.all) == 1 && len(forwardedSignals) > 0 {
+		groups.signals = make(chan os.Signal, 1)
+		signal.Notify(groups.signals, forwardedSignals...)
+		go forwardSignals(groups.signals)
+	}
+}
+
+// finishGroup forgets a process group whose leader has exited. See
+// waitExited.
+func finishGroup(p *os.Process) {
+	groups.Lock()
+	defer groups.Unlock()
+	delete(groups.all, p)
+	if len(groups.all) == 0 && groups.signals != nil {
+		signal.Stop(groups.signals)
+		close(groups.signals)
+		groups.signals = nil
+	}
+}
+
+func forwardSignals(signals <-chan os.Signal) {
+	for sig := range signals {
+		groups.Lock()
+		for p := range groups.all {
+			signalGroup(p, sig.(syscall.Signal))
+		}
+		groups.Unlock()
+	}
+}
+
+// signalRunningGroup sends sig to the process group led by p, unless the
+// leader has exited, in which case it returns os.ErrProcessDone.
+func signalRunningGroup(p *os.Process, sig syscall.Signal) error {
+	groups.Lock()
+	defer groups.Unlock()
+	if _, ok := groups.all[p]; !ok {
+		return os.ErrProcessDone
+	}
+	return signalGroup(p, sig)
+}
+
+// usesProcessGroup returns true if the shell runs scripts in their own process
+// group.
+func (sh *Shell) usesProcessGroup() bool {
+	return sh.timeout > 0 || sh.processGroup
+}
+
+// runCmd starts cmd and waits for it to exit. See startCmd.
+func (sh *Shell) runCmd(cmd *exec.Cmd) (timedOut bool, err error) {
+	if script, ok := sh.inProcessScript(cmd); ok {
+		wait, _ := sh.startInProcess(cmd, script)
+		return wait()
+	}
+	wait, err := sh.startCmd(cmd)
+	if err != nil {
+		return false, err
+	}
+	return wait()
+}
+
+// startCmd starts cmd, and returns a function that waits for it to exit. If
+// the shell has a Timeout or the ProcessGroup option, the script runs in its
+// own process group. A timed out script is sent SIGTERM, and SIGKILL after the
+// grace period, along with every process it started.
+func (sh *Shell) startCmd(cmd *exec.Cmd) (wait func() (timedOut bool, err error), err error) {
+	if !sh.usesProcessGroup() {
+		if err := cmd.Start(); err != nil {
+			removeScriptFile(cmd)
+			return nil, err
+		}
+		return func() (bool, error) {
+			defer removeScriptFile(cmd)
+			return false, cmd.Wait()
+		}, nil
+	}
+
+	setProcessGroup(cmd)
+	if cmd.Cancel != nil {
+		// exec.CommandContext only kills the script itself, which would let
+		// its children outlive it.
+		cmd.Cancel = func() error {
+			return signalRunningGroup(cmd.Process, syscall.SIGKILL)
+		}
+	}
+	if err := cmd.Start(); err != nil {
+		removeScriptFile(cmd)
+		return nil, err
+	}
+	startGroup(cmd.Process)
+
+	exited := make(chan struct{})
+	var done <-chan struct{}
+	if sh.ctx != nil {
+		done = sh.ctx.Done()
+	}
+	go func() {
+		select {
+		case <-done:
+			// Commands not made by exec.CommandContext aren't killed by it.
+			signalRunningGroup(cmd.Process, syscall.SIGKILL)
+		case <-exited:
+		}
+	}()
+
+	timeout := make(chan bool, 1)
+	// Closed once a timed out script's group was sent SIGKILL.
+	killed := make(chan struct{})
+	var timer *time.Timer
+	if sh.timeout > 0 {
+		grace := sh.gracePeriod
+		if grace <= 0 {
+			grace = DefaultGracePeriod
+		}
+		timer = time.AfterFunc(sh.timeout, func() {
+			defer close(killed)
+			timeout <- true
+			signalRunningGroup(cmd.Process, syscall.SIGTERM)
+			select {
+			case <-exited:
+			case <-time.After(grace):
+			}
+			// Also clean up any children that outlived the script. This does
+			// nothing once the script was reaped.
+			signalRunningGroup(cmd.Process, syscall.SIGKILL)
+		})
+	}
+
+	return func() (timedOut bool, err error) {
+		// Stop signalling the group before its leader is reaped, or just
+		// after if there's no way to tell that it exited.
+		if waitExited(cmd.Process) {
+			close(exited)
+			if timer != nil && !timer.Stop() {
+				// Let the timeout kill the rest of the group first.
+				<-killed
+			}
+			if done != nil && sh.ctx.Err() != nil {
+				// The script may have exited before the context's goroutines
+				// killed its group.
+				signalRunningGroup(cmd.Process, syscall.SIGKILL)
+			}
+			finishGroup(cmd.Process)
+			err = cmd.Wait()
+		} else {
+			err = cmd.Wait()
+			finishGroup(cmd.Process)
+			close(exited)
+		}
+		removeScriptFile(cmd)
+		if timer != nil {
+			timer.Stop()
+		}
+		select {
+		case timedOut = <-timeout:
+		default:
+		}
+		return timedOut, err
+	}, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package shell
+
+import "os"
+
+// waitExited returns false at once, because only Linux's waitid can wait for
+// a process to exit without reaping it.
+func waitExited(p *os.Process) bool {
+	return false
+}
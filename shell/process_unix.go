@@ -0,0 +1,28 @@
+//go:build !windows
+// +build !windows
+
+package shell
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup makes cmd start in its own process group, so the script and
+// every process it starts can be signalled together.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// signalGroup sends sig to the process group led by p, which must have been
+// started with setProcessGroup.
+func signalGroup(p *os.Process, sig syscall.Signal) error {
+	return syscall.Kill(-p.Pid, sig)
+}
+
+// Signals forwarded to running process groups. See ProcessGroup.
+var forwardedSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
@@ -0,0 +1,97 @@
+//go:build !windows
+// +build !windows
+
+package shell
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestProcessGroupForwardsSignals(t *testing.T) {
+	sh := (&Shell{}).With(ProcessGroup)
+	results := make(chan *Result)
+	go func() {
+		results <- sh.Do(`sleep 5`)
+	}()
+
+	for start := time.Now(); time.Since(start) < 2*time.Second; time.Sleep(10 * time.Millisecond) {
+		groups.Lock()
+		running := len(groups.all)
+		groups.Unlock()
+		if running > 0 {
+			break
+		}
+	}
+	syscall.Kill(os.Getpid(), syscall.SIGINT)
+
+	select {
+	case res := <-results:
+		if res.Signal != syscall.SIGINT {
+			t.Errorf("Do(`sleep 5`) -> Signal %v, expected forwarded SIGINT", res.Signal)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("SIGINT was not forwarded")
+	}
+}
+
+func TestProcessGroupContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	sh := (&Shell{}).With(ProcessGroup, Context(ctx))
+
+	// The background sleep keeps Stdout open, so Do returns only once the
+	// whole group is killed.
+	start := time.Now()
+	sh.Do(`sleep 5 & sleep 5`)
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("Children were not killed when the context was done")
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	sh := (&Shell{}).With(ProcessGroup)
+	orphan, err := strconv.Atoi(sh.Out(`sleep 5 >/dev/null 2>&1 & echo $!`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Kill(orphan, syscall.SIGKILL)
+	job := sh.Start(`sleep 5 & echo $!; wait`)
+	var child int
+	for start := time.Now(); child == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 2*time.Second {
+			t.Fatalf("Job did not print its child's pid")
+		}
+		child, _ = strconv.Atoi(strings.TrimSpace(job.Stdout()))
+	}
+
+	Cleanup()
+	if res := job.Wait(); res.Signal != syscall.SIGKILL {
+		t.Errorf("Cleanup() did not kill the running script: %v", res.Err())
+	}
+	for start := time.Now(); alive(child); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 2*time.Second {
+			t.Fatalf("Cleanup() did not kill sleep %d, the running script's child", child)
+		}
+	}
+	if !alive(orphan) {
+		t.Errorf("Cleanup() killed sleep %d, whose script was already reaped", orphan)
+	}
+}
+
+// alive returns true if pid is running, and not a zombie waiting for a parent
+// to reap it.
+func alive(pid int) bool {
+	stat, err := ioutil.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return syscall.Kill(pid, 0) == nil
+	}
+	fields := strings.Fields(string(stat))
+	return len(fields) > 2 && fields[2] != "Z"
+}
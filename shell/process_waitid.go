@@ -0,0 +1,26 @@
+//go:build linux
+// +build linux
+
+package shell
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// waitExited blocks until p has exited, without reaping it, so its process
+// ID can't be reused until it is waited for. It returns false if it can't
+// wait for p.
+func waitExited(p *os.Process) bool {
+	const pPID = 1
+	// Big enough for a siginfo_t.
+	var info [128]byte
+	for {
+		_, _, errno := syscall.Syscall6(syscall.SYS_WAITID, pPID, uintptr(p.Pid),
+			uintptr(unsafe.Pointer(&info)), syscall.WEXITED|syscall.WNOWAIT, 0, 0)
+		if errno != syscall.EINTR {
+			return errno == 0
+		}
+	}
+}
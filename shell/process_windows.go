@@ -0,0 +1,26 @@
+package shell
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup does nothing on Windows.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// signalGroup kills p, because Windows can't deliver other signals or signal
+// a whole process tree.
+func signalGroup(p *os.Process, sig syscall.Signal) error {
+	return p.Kill()
+}
+
+// waitExited returns false at once. Windows signals a process by its handle,
+// which is not reused like a process ID, so it doesn't matter when a process
+// group is forgotten.
+func waitExited(p *os.Process) bool {
+	return false
+}
+
+// Windows can't deliver signals to other processes, so none are forwarded.
+var forwardedSignals []os.Signal
@@ -0,0 +1,768 @@
+package shell
+
+// Escaping values according to where they appear in a script, like
+// html/template does for HTML.
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	shellquote "github.com/kballard/go-shellquote"
+)
+
+// ContextError is returned, or panicked with, when a value is interpolated
+// into a part of a script where it can't be escaped safely, like inside
+// backticks. Use a Raw value to interpolate it anyway.
+type ContextError struct {
+	// Where the value was, like "inside backticks".
+	Context string
+}
+
+func (e *ContextError) Error() string {
+	return fmt.Sprintf("can't safely escape a value %s", e.Context)
+}
+
+// quotePOSIX quotes s as a single word for Bash, sh, and zsh.
+func quotePOSIX(s string) string {
+	if strings.ContainsAny(s, "#{}~") && !strings.ContainsAny(s, " \t\n") {
+		// go-shellquote would leave these unescaped, but they start a comment,
+		// brace expansion, or tilde expansion.
+		return quoteSingle(s)
+	}
+	return shellquote.Join(s)
+}
+
+// quoteSingle quotes s with single quotes.
+func quoteSingle(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+var (
+	doubleQuoteEscaper    = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "`", "\\`")
+	heredocEscaper        = strings.NewReplacer(`\`, `\\`, `$`, `\$`, "`", "\\`")
+	arithmeticOperand     = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	errValueChangesSyntax = &ContextError{"that changes the syntax of the script"}
+	errArithmetic         = &ContextError{"in arithmetic, unless it is an integer"}
+)
+
+// scriptWriter builds a script out of text and values. If quote is nil,
+// values are escaped for the part of the script they are in, which is
+// tracked by a scriptLexer. Otherwise they are escaped with quote. Secret
+// values are added to secrets, if set.
+type scriptWriter struct {
+	quote   func(s string) string
+	secrets *secretSet
+	lex     scriptLexer
+	out     strings.Builder
+}
+
+// writeText writes part of the script.
+func (w *scriptWriter) writeText(text string) {
+	w.out.WriteString(text)
+	if w.quote == nil {
+		w.lex.feed(text, false)
+	}
+}
+
+// writeValue escapes val and writes it. Raw values are written as they are.
+func (w *scriptWriter) writeValue(val interface{}) error {
+	switch v := val.(type) {
+	case Raw:
+		w.writeText(string(v))
+		return nil
+	case Escaper:
+		w.writeText(string(v.Escape()))
+		return nil
+	}
+	if w.quote != nil {
+		w.out.WriteString(string(escape(w.quote, w.secrets, val)))
+		return nil
+	}
+
+	kind, err := w.lex.context()
+	if err != nil {
+		return err
+	}
+	if words, ok := expand(val); ok {
+		return w.writeWords(kind, words)
+	}
+	var s string
+	_, isSecret := val.(Secret)
+	switch v := val.(type) {
+	case Secret:
+		s = string(v)
+	case []byte:
+		s = string(v)
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	// Some words are evaluated as arithmetic, even inside quotes.
+	arith := kind == inArith
+	if cmd := w.lex.command(); cmd != nil && kind != inHeredoc {
+		if cmd.arithmetic() {
+			arith = true
+		} else if cmd.test && !arithmeticOperand.MatchString(s) {
+			// An error if the next word is an arithmetic operator.
+			cmd.nonInteger = true
+		}
+	}
+
+	var escaped string
+	switch {
+	case arith:
+		if !arithmeticOperand.MatchString(s) {
+			return errArithmetic
+		}
+		escaped = s
+	case kind == inWord || kind == inSubst:
+		escaped = quotePOSIX(s)
+	case kind == inSingle:
+		escaped = strings.Replace(s, `'`, `'\''`, -1)
+	case kind == inDouble:
+		escaped = doubleQuoteEscaper.Replace(s)
+	case kind == inHeredoc:
+		if w.lex.top().heredoc.quoted {
+			escaped = s
+		} else {
+			escaped = heredocEscaper.Replace(s)
+		}
+	}
+	if isSecret {
+		w.secrets.add(s, escaped)
+	}
+
+	depth := len(w.lex.stack)
+	w.lex.feed(escaped, true)
+	if w.lex.err != nil {
+		return w.lex.err
+	}
+	if len(w.lex.stack) != depth || w.lex.top().kind != kind || w.lex.pending() {
+		return errValueChangesSyntax
+	}
+	w.out.WriteString(escaped)
+	return nil
+}
+
+// writeWords writes the words of a slice, map or Arger. Outside of quotes,
+// each word is escaped separately. Elsewhere, the words are joined by spaces,
+// like "$*".
+func (w *scriptWriter) writeWords(kind syntaxKind, words []interface{}) error {
+	if kind == inWord || kind == inSubst {
+		for i, word := range words {
+			if i > 0 {
+				w.writeText(" ")
+			}
+			if err := w.writeValue(word); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	joined := make([]string, len(words))
+	for i, word := range words {
+		joined[i] = string(ToRaw(word))
+	}
+	return w.writeValue(strings.Join(joined, " "))
+}
+
+// script returns the script written so far.
+func (w *scriptWriter) script() (string, error) {
+	return w.out.String(), w.lex.err
+}
+
+// mustScript is like script, but panics on error.
+func (w *scriptWriter) mustScript() string {
+	script, err := w.script()
+	if err != nil {
+		panic(err)
+	}
+	return script
+}
+
+// syntaxKind is a part of a shell script that a value can be in.
+type syntaxKind int
+
+const (
+	inWord         syntaxKind = iota // a bare word, including assignments
+	inSubst                          // $(...)
+	inSingle                         // '...'
+	inDouble                         // "..."
+	inDollarSingle                   // $'...'
+	inBacktick                       // `...`
+	inArith                          // $((...)) or ((...))
+	inParam                          // ${...}
+	inComment                        // # ...
+	inHeredoc                        // the body of <<EOF
+)
+
+type syntaxFrame struct {
+	kind syntaxKind
+	// Open parentheses, for inSubst and inArith.
+	depth int
+	// For inArith, true after the first ) of )).
+	closing bool
+	// For inHeredoc.
+	heredoc *heredoc
+	// For inWord and inSubst, the command being read.
+	cmd *command
+}
+
+// command follows the words of a simple command, or of a [[ ... ]] test,
+// well enough to know which words Bash evaluates as arithmetic.
+type command struct {
+	// The current word so far, including any quotes.
+	word strings.Builder
+	// The command name, once it has been read.
+	name string
+	// For declare and the like, true after an option like -i.
+	integer bool
+	// True inside [[ ... ]].
+	test bool
+	// The last word, and whether it or the current word contain a value that
+	// isn't an integer. Only tracked inside [[ ... ]].
+	last           string
+	lastNonInteger bool
+	nonInteger     bool
+}
+
+var (
+	// Operators of [[ ... ]] whose operands are arithmetic.
+	arithmeticTests = map[string]bool{"-eq": true, "-ne": true, "-lt": true, "-le": true, "-gt": true, "-ge": true}
+	// Words that can come before a command name.
+	commandPrefixes = map[string]bool{"!": true, "{": true, "if": true, "then": true, "elif": true, "else": true, "while": true, "until": true, "do": true, "time": true}
+	// Commands that declare variables, and can give them the integer
+	// attribute.
+	declarations = map[string]bool{"declare": true, "typeset": true, "local": true}
+	// Commands whose arguments can be array elements, like `unset 'arr[0]'`.
+	elementCommands = map[string]bool{"declare": true, "typeset": true, "local": true, "export": true, "readonly": true, "unset": true}
+	assignment      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\[.*\])?\+?=`)
+	integerOption   = regexp.MustCompile(`^-[A-Za-z]*i`)
+	// An array subscript, like `arr[`, which is arithmetic for indexed arrays.
+	openSubscript = regexp.MustCompile(`^['"]?[A-Za-z_][A-Za-z0-9_]*\[[^\]]*$`)
+)
+
+// arithmetic returns true if the current word is evaluated as arithmetic: an
+// argument of let or of declare -i, the subscript of an array element being
+// assigned or declared, or an operand of an arithmetic test in [[ ... ]].
+func (c *command) arithmetic() bool {
+	switch {
+	case c.test:
+		return arithmeticTests[c.last]
+	case c.name == "let":
+		return true
+	case declarations[c.name] && c.integer:
+		return true
+	}
+	return (c.name == "" || elementCommands[c.name]) && openSubscript.MatchString(c.word.String())
+}
+
+// endWord finishes the current word. It returns an error if the word is an
+// arithmetic test whose first operand isn't an integer.
+func (c *command) endWord() error {
+	word := c.word.String()
+	c.word.Reset()
+	if word == "" {
+		return nil
+	}
+	if c.test {
+		if word == "]]" {
+			c.test = false
+		}
+		nonInteger := c.lastNonInteger
+		c.last, c.lastNonInteger, c.nonInteger = word, c.nonInteger, false
+		if arithmeticTests[word] && nonInteger {
+			return errArithmetic
+		}
+		return nil
+	}
+	switch {
+	case c.name == "" && (commandPrefixes[word] || assignment.MatchString(word)):
+		// Still before the command name.
+	case c.name == "":
+		c.name = word
+		c.test = word == "[["
+	case declarations[c.name] && integerOption.MatchString(word):
+		c.integer = true
+	}
+	return nil
+}
+
+// endCommand finishes the command, unless it is a [[ ... ]] test, which can
+// contain && and the like.
+func (c *command) endCommand() {
+	if !c.test {
+		c.name, c.integer = "", false
+	}
+}
+
+type heredoc struct {
+	delim     string
+	quoted    bool
+	stripTabs bool
+}
+
+// scriptLexer follows the structure of a shell script one character at a
+// time, well enough to know what kind of syntax the next character is in.
+type scriptLexer struct {
+	stack   []syntaxFrame
+	escaped bool
+	// 1 after $, 2 after $(.
+	dollar int
+	// Number of < in a row.
+	lt int
+	// True after ( at the start of a word, so (( can be recognized.
+	paren bool
+	// Length of the current word.
+	wordLen int
+
+	// The heredoc delimiter being read, after <<.
+	delim        *heredoc
+	delimStarted bool
+	delimQuote   rune
+	// Heredocs whose bodies start on the next line.
+	heredocs []*heredoc
+	// The current line of a heredoc body, and whether a value is on it.
+	line    strings.Builder
+	touched bool
+	// True once the current word has ended, during step.
+	wordEnded bool
+
+	err error
+}
+
+func (l *scriptLexer) top() *syntaxFrame {
+	if len(l.stack) == 0 {
+		l.stack = append(l.stack, syntaxFrame{kind: inWord})
+	}
+	return &l.stack[len(l.stack)-1]
+}
+
+func (l *scriptLexer) push(kind syntaxKind) {
+	l.top()
+	l.stack = append(l.stack, syntaxFrame{kind: kind})
+}
+
+func (l *scriptLexer) pop() {
+	if len(l.stack) > 1 {
+		l.stack = l.stack[:len(l.stack)-1]
+	}
+}
+
+// command returns the command the next character is in, or nil if it isn't
+// in a command, like in a comment or the body of a heredoc.
+func (l *scriptLexer) command() *command {
+	for i := len(l.stack) - 1; i >= 0; i-- {
+		switch frame := &l.stack[i]; frame.kind {
+		case inWord, inSubst:
+			if frame.cmd == nil {
+				frame.cmd = &command{}
+			}
+			return frame.cmd
+		case inComment, inHeredoc:
+			return nil
+		}
+	}
+	l.top()
+	return l.command()
+}
+
+// endWord finishes the current word of the command the lexer is in.
+func (l *scriptLexer) endWord(top *syntaxFrame) {
+	l.wordEnded = true
+	if top.cmd == nil {
+		return
+	}
+	if err := top.cmd.endWord(); err != nil && l.err == nil {
+		l.err = err
+	}
+}
+
+// endCommand finishes the current word and command.
+func (l *scriptLexer) endCommand(top *syntaxFrame) {
+	l.endWord(top)
+	if top.cmd != nil {
+		top.cmd.endCommand()
+	}
+}
+
+// pending returns true if the lexer is waiting to see the next character.
+func (l *scriptLexer) pending() bool {
+	return l.escaped || l.dollar > 0 || l.lt > 0 || l.delim != nil
+}
+
+// context returns the kind of syntax the next character is in, or an error
+// if a value can't safely be escaped there.
+func (l *scriptLexer) context() (syntaxKind, error) {
+	switch {
+	case l.escaped:
+		return 0, &ContextError{"after a backslash"}
+	case l.delim != nil || l.lt == 2:
+		return 0, &ContextError{"as a heredoc delimiter"}
+	case l.dollar == 1:
+		return 0, &ContextError{"after $"}
+	case l.dollar == 2:
+		l.dollar = 0
+		l.push(inSubst)
+	}
+	l.lt = 0
+	l.paren = false
+
+	switch kind := l.top().kind; kind {
+	case inBacktick:
+		return 0, &ContextError{"inside backticks"}
+	case inDollarSingle:
+		return 0, &ContextError{"inside $'...'"}
+	case inParam:
+		return 0, &ContextError{"inside ${...}"}
+	case inComment:
+		return 0, &ContextError{"in a comment"}
+	default:
+		return kind, nil
+	}
+}
+
+// feed advances the lexer past s, which is part of a value if value is true.
+func (l *scriptLexer) feed(s string, value bool) {
+	for _, c := range s {
+		if value && l.top().kind == inHeredoc {
+			l.touched = true
+		}
+		l.step(c)
+	}
+}
+
+func (l *scriptLexer) step(c rune) {
+	cmd := l.command()
+	l.wordEnded = false
+	l.stepSyntax(c)
+	if cmd != nil && !l.wordEnded {
+		cmd.word.WriteRune(c)
+	}
+}
+
+func (l *scriptLexer) stepSyntax(c rune) {
+	top := l.top()
+	if l.escaped {
+		l.escaped = false
+		l.wordLen++
+		if top.kind == inHeredoc {
+			l.line.WriteRune(c)
+		}
+		return
+	}
+	if l.dollar > 0 && l.afterDollar(c) {
+		return
+	}
+	top = l.top()
+	if top.kind == inWord || top.kind == inSubst {
+		if l.afterLess(c) {
+			return
+		}
+		if l.delim != nil && l.readDelim(c) {
+			return
+		}
+	}
+
+	switch top.kind {
+	case inWord, inSubst:
+		l.stepWord(top, c)
+	case inSingle:
+		if c == '\'' {
+			l.pop()
+		}
+	case inDollarSingle:
+		switch c {
+		case '\\':
+			l.escaped = true
+		case '\'':
+			l.pop()
+		}
+	case inDouble:
+		switch c {
+		case '\\':
+			l.escaped = true
+		case '"':
+			l.pop()
+		case '$':
+			l.dollar = 1
+		case '`':
+			l.push(inBacktick)
+		}
+	case inBacktick:
+		switch c {
+		case '\\':
+			l.escaped = true
+		case '`':
+			l.pop()
+		}
+	case inArith:
+		switch c {
+		case '(':
+			top.depth++
+		case ')':
+			if top.depth > 0 {
+				top.depth--
+			} else if top.closing {
+				l.pop()
+			} else {
+				top.closing = true
+			}
+		case '$':
+			l.dollar = 1
+		}
+	case inParam:
+		switch c {
+		case '}':
+			l.pop()
+		case '$':
+			l.dollar = 1
+		case '\'':
+			l.push(inSingle)
+		case '"':
+			l.push(inDouble)
+		}
+	case inComment:
+		if c == '\n' {
+			l.pop()
+			l.step(c)
+		}
+	case inHeredoc:
+		l.stepHeredoc(top, c)
+	}
+}
+
+// afterDollar handles the character after $ or $(. Returns true if c was
+// consumed.
+func (l *scriptLexer) afterDollar(c rune) bool {
+	dollar := l.dollar
+	l.dollar = 0
+	if dollar == 2 {
+		if c == '(' {
+			l.push(inArith)
+			return true
+		}
+		l.push(inSubst)
+		return false
+	}
+	switch c {
+	case '(':
+		l.dollar = 2
+		return true
+	case '{':
+		l.push(inParam)
+		return true
+	case '\'':
+		if kind := l.top().kind; kind == inWord || kind == inSubst {
+			l.push(inDollarSingle)
+			return true
+		}
+	}
+	return false
+}
+
+// afterLess recognizes << and <<<. Returns true if c was consumed.
+func (l *scriptLexer) afterLess(c rune) bool {
+	if c == '<' {
+		l.endWord(l.top())
+		l.lt++
+		if l.lt == 3 {
+			// A here-string, which is an ordinary word.
+			l.lt = 0
+		}
+		l.wordLen = 0
+		return true
+	}
+	if l.lt == 2 {
+		l.delim = &heredoc{stripTabs: c == '-'}
+		l.delimStarted = false
+		l.lt = 0
+		return c == '-'
+	}
+	l.lt = 0
+	return false
+}
+
+// readDelim reads the delimiter word of a heredoc. Returns true if c was
+// consumed.
+func (l *scriptLexer) readDelim(c rune) bool {
+	d := l.delim
+	if !l.delimStarted {
+		if c == ' ' || c == '\t' {
+			return true
+		}
+		l.delimStarted = true
+	}
+	if l.delimQuote != 0 {
+		if c == l.delimQuote {
+			l.delimQuote = 0
+		} else {
+			d.delim += string(c)
+		}
+		return true
+	}
+	switch {
+	case c == '\'' || c == '"':
+		d.quoted = true
+		l.delimQuote = c
+	case c == '\\':
+		d.quoted = true
+	case strings.ContainsRune(" \t\n;|&<>()", c):
+		l.heredocs = append(l.heredocs, d)
+		l.delim = nil
+		return false
+	default:
+		d.delim += string(c)
+	}
+	return true
+}
+
+func (l *scriptLexer) stepWord(top *syntaxFrame, c rune) {
+	paren := l.paren
+	l.paren = false
+	switch c {
+	case '\\':
+		l.escaped = true
+	case '\'':
+		l.push(inSingle)
+		l.wordLen++
+	case '"':
+		l.push(inDouble)
+		l.wordLen++
+	case '`':
+		l.push(inBacktick)
+		l.wordLen++
+	case '$':
+		l.dollar = 1
+		l.wordLen++
+	case '#':
+		if l.wordLen == 0 {
+			l.push(inComment)
+		} else {
+			l.wordLen++
+		}
+	case '(':
+		if paren {
+			if top.kind == inSubst {
+				top.depth--
+			}
+			l.push(inArith)
+			return
+		}
+		if top.kind == inSubst {
+			top.depth++
+		}
+		l.endCommand(top)
+		l.paren = l.wordLen == 0
+		l.wordLen = 0
+	case ')':
+		l.endCommand(top)
+		if top.kind == inSubst {
+			if top.depth == 0 {
+				l.pop()
+			} else {
+				top.depth--
+			}
+		}
+		l.wordLen = 0
+	case '\n':
+		l.endCommand(top)
+		l.wordLen = 0
+		for i := len(l.heredocs) - 1; i >= 0; i-- {
+			l.push(inHeredoc)
+			l.top().heredoc = l.heredocs[i]
+		}
+		l.heredocs = nil
+	case ';', '|', '&':
+		l.endCommand(top)
+		l.wordLen = 0
+	case ' ', '\t', '>':
+		l.endWord(top)
+		l.wordLen = 0
+	default:
+		l.wordLen++
+	}
+}
+
+func (l *scriptLexer) stepHeredoc(top *syntaxFrame, c rune) {
+	if c == '\n' {
+		line := l.line.String()
+		if top.heredoc.stripTabs {
+			line = strings.TrimLeft(line, "\t")
+		}
+		l.line.Reset()
+		touched := l.touched
+		l.touched = false
+		if line == top.heredoc.delim {
+			if touched && l.err == nil {
+				l.err = &ContextError{"that ends a heredoc"}
+			}
+			l.pop()
+		}
+		return
+	}
+	l.line.WriteRune(c)
+	if top.heredoc.quoted {
+		return
+	}
+	switch c {
+	case '\\':
+		l.escaped = true
+	case '$':
+		l.dollar = 1
+	case '`':
+		l.push(inBacktick)
+	}
+}
+
+// scriptFormatArg is passed to fmt.Sprintf in place of each value by
+// scriptPrintf. It formats the value, saves it for scriptPrintf to escape,
+// and prints a placeholder.
+type scriptFormatArg struct {
+	val    interface{}
+	values *[]interface{}
+}
+
+// Placeholders are "\x00N\x00", where N is the index of a value. Scripts
+// can't contain NUL.
+const placeholderDelim = "\x00"
+
+func (a scriptFormatArg) Format(f fmt.State, verb rune) {
+	val := a.val
+	spec := formatSpec(f, verb)
+	if spec != "%s" && spec != "%v" {
+		formatted := fmt.Sprintf(spec, val)
+		switch val.(type) {
+		case Raw:
+			val = Raw(formatted)
+		case Secret:
+			val = Secret(formatted)
+		default:
+			val = formatted
+		}
+	}
+	*a.values = append(*a.values, val)
+	fmt.Fprintf(f, "%s%d%s", placeholderDelim, len(*a.values)-1, placeholderDelim)
+}
+
+// formatSpec rebuilds the verb that fmt is formatting.
+func formatSpec(f fmt.State, verb rune) string {
+	var spec strings.Builder
+	spec.WriteRune('%')
+	for _, flag := range "+-# 0" {
+		if f.Flag(int(flag)) {
+			spec.WriteRune(flag)
+		}
+	}
+	if width, ok := f.Width(); ok {
+		spec.WriteString(strconv.Itoa(width))
+	}
+	if precision, ok := f.Precision(); ok {
+		spec.WriteRune('.')
+		spec.WriteString(strconv.Itoa(precision))
+	}
+	spec.WriteRune(verb)
+	return spec.String()
+}
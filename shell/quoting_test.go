@@ -0,0 +1,135 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScriptPrintfContexts(t *testing.T) {
+	cases := []struct {
+		format string
+		v      interface{}
+		out    string
+	}{
+		{`echo %s`, "a b", `echo 'a b'`},
+		{`echo %s`, "#not-a-comment", `echo '#not-a-comment'`},
+		{`echo %s`, "{a,b}", `echo '{a,b}'`},
+		{`PATH=%s`, "~/bin:~/go/bin", `PATH='~/bin:~/go/bin'`},
+		{`echo --name=%s`, "it's", `echo --name=it\'s`},
+		{`echo 'Hello, %s!'`, "it's", `echo 'Hello, it'\''s!'`},
+		{`echo "Hello, %s!"`, "$USER `id` \"x\"", `echo "Hello, \$USER \` + "`id\\`" + ` \"x\"!"`},
+		{`echo "$(basename %s)"`, "a b", `echo "$(basename 'a b')"`},
+		{`echo $(( 1 + %d ))`, 2, `echo $(( 1 + 2 ))`},
+		{"cat <<EOF\nHello, %s\nEOF", "$USER", "cat <<EOF\nHello, \\$USER\nEOF"},
+		{"cat <<'EOF'\nHello, %s\nEOF", "$USER", "cat <<'EOF'\nHello, $USER\nEOF"},
+		{`[[ -n %s ]]`, "a b", `[[ -n 'a b' ]]`},
+		{`[[ %s == x && 1 -eq 1 ]]`, "a b", `[[ 'a b' == x && 1 -eq 1 ]]`},
+		{`[[ %s -eq 1 ]]`, 2, `[[ 2 -eq 1 ]]`},
+		{`[[ 1 -lt "%s" ]]`, 2, `[[ 1 -lt "2" ]]`},
+		{`let n=%s+1`, 2, `let n=2+1`},
+		{`declare -i n=%s`, -2, `declare -i n=-2`},
+		{`arr[%s]=x`, 0, `arr[0]=x`},
+		{`declare -a arr; arr[0]=%s`, "a b", `declare -a arr; arr[0]='a b'`},
+		{`let n=1; echo %s`, "a b", `let n=1; echo 'a b'`},
+		{`ls file[%s].txt`, "a", `ls file[a].txt`},
+		{`echo %s`, Raw(`"a b"`), `echo "a b"`},
+	}
+
+	for _, c := range cases {
+		actual := ScriptPrintf(c.format, c.v)
+		if actual != c.out {
+			t.Errorf("ScriptPrintf(%q, %q) ->\n%s\n  !=\n%s", c.format, c.v, actual, c.out)
+		}
+	}
+
+	// A value after a heredoc is escaped as a word again.
+	format := "cat <<-EOF\n\tHello, %s\n\tEOF\necho %s"
+	expected := "cat <<-EOF\n\tHello, \\$USER\n\tEOF\necho 'a b'"
+	if actual := ScriptPrintf(format, "$USER", "a b"); actual != expected {
+		t.Errorf("ScriptPrintf(%q, ...) ->\n%s\n  !=\n%s", format, actual, expected)
+	}
+}
+
+func TestScriptPrintRawContext(t *testing.T) {
+	out := ScriptPrint(Raw(`echo "`), "$HOME", Raw(`"`))
+	if out != `echo "\$HOME"` {
+		t.Errorf("ScriptPrint with a Raw quote -> %s", out)
+	}
+}
+
+func TestScriptPrintfUnsafeContexts(t *testing.T) {
+	cases := []struct {
+		format string
+		v      interface{}
+		err    string
+	}{
+		{"echo `basename %s`", "x", "inside backticks"},
+		{`echo hi # %s`, "x\nrm -rf /", "in a comment"},
+		{`echo ${FOO:-%s}`, "x", "inside ${...}"},
+		{`echo $%s`, "x", "after $"},
+		{`echo $(( 1 + %s ))`, "x; rm", "in arithmetic, unless it is an integer"},
+		{`[[ %s -eq 1 ]]`, "a[$(id)]", "in arithmetic, unless it is an integer"},
+		{`[[ "%s" -ne 1 ]]`, "x", "in arithmetic, unless it is an integer"},
+		{`[[ 1 -eq '%s' ]]`, "x", "in arithmetic, unless it is an integer"},
+		{`let %s`, "x=$(id)", "in arithmetic, unless it is an integer"},
+		{`declare -i n=%s`, "x", "in arithmetic, unless it is an integer"},
+		{`local -ri n="%s"`, "x", "in arithmetic, unless it is an integer"},
+		{`arr[%s]=1`, "x", "in arithmetic, unless it is an integer"},
+		{`declare arr["%s"]=1`, "x", "in arithmetic, unless it is an integer"},
+		{`unset 'arr[%s]'`, "x", "in arithmetic, unless it is an integer"},
+		{"cat <<%s\nEOF", "EOF", "as a heredoc delimiter"},
+		{"cat <<EOF\n%s\nEOF", "x\nEOF\nrm -rf /", "that ends a heredoc"},
+		{"cat <<EOF\n%sOF\nEOF", "E", "that ends a heredoc"},
+	}
+
+	for _, c := range cases {
+		func() {
+			defer func() {
+				err, _ := recover().(*ContextError)
+				if err == nil || !strings.HasSuffix(err.Error(), c.err) {
+					t.Errorf("ScriptPrintf(%q, %q) -> panic(%v), expected %q", c.format, c.v, err, c.err)
+				}
+			}()
+			ScriptPrintf(c.format, c.v)
+		}()
+	}
+}
+
+func TestScriptTemplateContexts(t *testing.T) {
+	tmpl := "git commit -m \"#{MESSAGE}\" --author='#{AUTHOR}'"
+	out := ScriptTemplate(tmpl, Vars{"MESSAGE": `say "hi"`, "AUTHOR": "O'Brien"})
+	expected := `git commit -m "say \"hi\"" --author='O'\''Brien'`
+	if out != expected {
+		t.Errorf("ScriptTemplate(%q) -> %s != %s", tmpl, out, expected)
+	}
+
+	tpl, err := ParseScriptTemplate("echo `#{X}`")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tpl.Render(Vars{"X": "x"}); err == nil || err.Error() != "script template:1:7: can't safely escape a value inside backticks" {
+		t.Errorf("Render(%q) -> %v", tpl, err)
+	}
+	if out, err := tpl.Render(Vars{"X": Raw("x")}); err != nil || out != "echo `x`" {
+		t.Errorf("Render(%q) with Raw -> %q, %v", tpl, out, err)
+	}
+}
+
+func TestContextEscapingRuns(t *testing.T) {
+	sh := &Shell{}
+	values := []string{"a b", "it's", `"quoted"`, "$HOME `id` \\n", "#{x,y}", "~/x"}
+	for _, v := range values {
+		formats := []string{
+			`printf '%%s' %s`,
+			`printf '%%s' '%s'`,
+			`printf '%%s' "%s"`,
+			"cat <<EOF\n%s\nEOF",
+			`X=%s; printf '%%s' "$X"`,
+		}
+		for _, format := range formats {
+			if out := sh.Outf(format, v); out != v {
+				t.Errorf("Outf(%q, %q) -> %q", format, v, out)
+			}
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package shell
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Result describes a script that ran to completion.
+type Result struct {
+	// The script that was run. For argv methods like ExecDo, this is the
+	// command quoted with shellquote.Join.
+	Script string
+	// Captured Stdout, minus the last trailing newline. Empty if Stdout was
+	// connected to the Shell's Stdout.
+	Stdout string
+	// Captured Stderr, minus the last trailing newline. Empty if Stderr was
+	// connected to the Shell's Stderr.
+	Stderr string
+	// Captured Stdout and Stderr, interleaved roughly in the order they were
+	// written.
+	Combined string
+	// Exit code of the process, or -1 if the process was killed by a signal or
+	// could not be started.
+	ExitCode int
+	// Signal that killed the process, if any.
+	Signal os.Signal
+	// Process ID of the script, or 0 if the process could not be started or
+	// the script ran in-process. See Interpreter.Run.
+	Pid   int
+	Start time.Time
+	End   time.Time
+	// True if the script was killed because it ran longer than the shell's
+	// Timeout.
+	TimedOut bool
+	// Number of times the script was run. See Retries.
+	Attempts int
+	// An *ExitError if the process exited non-zero, or some other error if
+	// the process could not be run.
+	err error
+	// A panic from the ErrorPolicy, raised again by Err. See Stream.
+	panic interface{}
+	// Stops the script, if it runs in-process.
+	cancel func()
+}
+
+// Err returns nil if the script exited 0. If the script exited non-zero, Err
+// returns an *ExitError. Otherwise, Err returns whatever error prevented
+// the script from running. If the shell's ErrorPolicy panicked in the
+// background, as it can for Stream, Err panics instead.
+func (r *Result) Err() error {
+	if r.panic != nil {
+		panic(r.panic)
+	}
+	return r.err
+}
+
+// Success returns true if the script exited 0.
+func (r *Result) Success() bool {
+	return r.Err() == nil
+}
+
+// Duration returns how long the script ran.
+func (r *Result) Duration() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+// Lines splits Stdout into lines. An empty Stdout has no lines.
+func (r *Result) Lines() []string {
+	if r.Stdout == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(r.Stdout, "\n"), "\n")
+}
+
+// lockedWriter serializes writes from a command's Stdout and Stderr copying
+// goroutines.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(p)
+}
+
+// do runs the command made by newCmd and describes it as a Result. If stdout
+// or stderr are nil, the respective stream is captured in the Result;
+// otherwise the stream is connected to the given writer. If the shell has a
+// Retry policy, newCmd is called again for each attempt.
+func (sh *Shell) do(newCmd func() *exec.Cmd, script string, stdout, stderr io.Writer) *Result {
+	if sh.Stdin != nil && sh.retry.Attempts > 1 {
+		// Every attempt needs the whole Stdin.
+		stdin, err := ioutil.ReadAll(sh.stdin())
+		if err != nil {
+			sh.onError(err)
+			return &Result{Script: script, ExitCode: -1, err: err}
+		}
+		makeCmd := newCmd
+		newCmd = func() *exec.Cmd {
+			cmd := makeCmd()
+			cmd.Stdin = bytes.NewReader(stdin)
+			return cmd
+		}
+	}
+
+	res := sh.attempt(newCmd(), script, stdout, stderr, 1)
+	for attempts := 1; sh.retry.retryAfter(attempts, res); attempts++ {
+		if !sh.sleep(sh.retry.backoff(attempts)) {
+			break
+		}
+		res = sh.attempt(newCmd(), script, stdout, stderr, attempts+1)
+	}
+	sh.onError(res.err)
+	return res
+}
+
+// attempt runs cmd once, as the given attempt of the script, and describes it
+// as a Result. See do.
+func (sh *Shell) attempt(cmd *exec.Cmd, script string, stdout, stderr io.Writer, attempt int) *Result {
+	res := &Result{Script: script, Attempts: attempt}
+
+	var outBuf, errBuf, combinedBuf bytes.Buffer
+	combined := &lockedWriter{w: &combinedBuf}
+	if stdout == nil {
+		stdout = io.MultiWriter(&outBuf, combined)
+	}
+	// Keep the tail of Stderr for an ExitError, even if it isn't captured.
+	tail := &tailWriter{}
+	if stderr == nil {
+		stderr = io.MultiWriter(&errBuf, combined, tail)
+	} else {
+		stderr = io.MultiWriter(stderr, tail)
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	sh.execute(cmd, res)
+	res.Stdout = sh.trim(outBuf.Bytes())
+	res.Stderr = sh.trim(errBuf.Bytes())
+	res.Combined = sh.trim(combinedBuf.Bytes())
+	res.err = wrapExitError(res.err, script, cmd.Dir, tail.String())
+	sh.trace(TraceFinish, cmd.Dir, res)
+	return res
+}
+
+// execute runs cmd to completion, and records the process in res.
+func (sh *Shell) execute(cmd *exec.Cmd, res *Result) {
+	sh.begin(cmd, res)()
+}
+
+// begin starts cmd, and records the process in res. The returned function
+// waits for cmd to exit, and records the exit status in res.
+func (sh *Shell) begin(cmd *exec.Cmd, res *Result) (wait func()) {
+	res.Start = time.Now()
+	if res.Attempts == 0 {
+		res.Attempts = 1
+	}
+	sh.trace(TraceStart, cmd.Dir, res)
+	if sh.dryRun(res.Script) {
+		removeScriptFile(cmd)
+		res.err = sh.DryRun.fake(cmd, res.Script, sh.maskSecrets)
+		res.End = time.Now()
+		res.ExitCode = stageStatus(res.err)
+		return func() {}
+	}
+
+	if script, ok := sh.inProcessScript(cmd); ok {
+		var waitCmd func() (bool, error)
+		waitCmd, res.cancel = sh.startInProcess(cmd, script)
+		return func() {
+			timedOut, err := waitCmd()
+			res.End = time.Now()
+			res.TimedOut = timedOut
+			res.err = err
+			res.setProcessState(cmd)
+		}
+	}
+	waitCmd, err := sh.startCmd(cmd)
+	if err != nil {
+		res.End = time.Now()
+		res.err = err
+		res.setProcessState(cmd)
+		return func() {}
+	}
+	if cmd.Process != nil {
+		res.Pid = cmd.Process.Pid
+	}
+	return func() {
+		timedOut, err := waitCmd()
+		res.End = time.Now()
+		res.TimedOut = timedOut
+		res.err = err
+		res.setProcessState(cmd)
+	}
+}
+
+// setProcessState records the exit status of cmd, which must have been run.
+// Scripts that ran in-process have no ProcessState, but fail with an
+// *ExitError.
+func (r *Result) setProcessState(cmd *exec.Cmd) {
+	if cmd.ProcessState != nil {
+		r.ExitCode = cmd.ProcessState.ExitCode()
+		r.Signal = exitSignal(cmd.ProcessState)
+		return
+	}
+	var exitErr *ExitError
+	switch {
+	case r.err == nil:
+		r.ExitCode = 0
+	case errors.As(r.err, &exitErr):
+		r.ExitCode = exitErr.Code
+		r.Signal = exitErr.Signal
+	default:
+		r.ExitCode = -1
+	}
+}
@@ -0,0 +1,72 @@
+package shell
+
+import (
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+func TestDo(t *testing.T) {
+	sh := &Shell{}
+	res := sh.Do(`echo out; sleep 0.05; echo err >&2; sleep 0.05; echo out2; exit 3`)
+	if res.Stdout != "out\nout2" || res.Stderr != "err" {
+		t.Errorf("Do(...) -> Stdout %q, Stderr %q", res.Stdout, res.Stderr)
+	}
+	if res.Combined != "out\nerr\nout2" {
+		t.Errorf("Do(...).Combined -> %q", res.Combined)
+	}
+	if res.ExitCode != 3 || res.Err() == nil || res.Pid == 0 {
+		t.Errorf("Do(...) -> ExitCode %d, Err %v, Pid %d", res.ExitCode, res.Err(), res.Pid)
+	}
+	if res.Duration() <= 0 {
+		t.Errorf("Do(...).Duration() -> %v", res.Duration())
+	}
+	if sh.LastError() == nil {
+		t.Errorf("Do should set LastError")
+	}
+
+	res = sh.Do(`kill -TERM $$`)
+	if res.Signal != syscall.SIGTERM || res.ExitCode != -1 {
+		t.Errorf("Do(kill) -> Signal %v, ExitCode %d", res.Signal, res.ExitCode)
+	}
+}
+
+func TestResultDecoding(t *testing.T) {
+	res := &Result{Stdout: `{"a": [1, 2]}`}
+	var v struct{ A []int }
+	if err := res.JSON(&v); err != nil || !reflect.DeepEqual(v.A, []int{1, 2}) {
+		t.Errorf("JSON -> %v, %#v", err, v)
+	}
+
+	cases := []struct {
+		stdout string
+		lines  []string
+	}{
+		{"", []string{}},
+		{"one", []string{"one"}},
+		{"one\ntwo\n", []string{"one", "two"}},
+	}
+	for _, c := range cases {
+		actual := (&Result{Stdout: c.stdout}).Lines()
+		if !reflect.DeepEqual(actual, c.lines) {
+			t.Errorf("Result{Stdout: %q}.Lines() -> %#v != %#v", c.stdout, actual, c.lines)
+		}
+	}
+}
+
+func TestMockCallResult(t *testing.T) {
+	cases := []struct {
+		call     MockCall
+		combined string
+	}{
+		{MockCall{Stdout: "out", Stderr: "err"}, "out\nerr"},
+		{MockCall{Stdout: "out"}, "out"},
+		{MockCall{Stderr: "err"}, "err"},
+		{MockCall{}, ""},
+	}
+	for _, c := range cases {
+		if res := c.call.Result(); res.Combined != c.combined {
+			t.Errorf("%#v.Result().Combined -> %q != %q", c.call, res.Combined, c.combined)
+		}
+	}
+}
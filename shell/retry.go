@@ -0,0 +1,114 @@
+package shell
+
+// Timeouts and retries for flaky scripts.
+
+import (
+	"time"
+)
+
+// Retry is a policy for running a script again after an attempt fails. See
+// Retries.
+type Retry struct {
+	// Maximum number of times to run the script, including the first attempt.
+	// Zero or one means the script is never retried.
+	Attempts int
+	// How long to wait before each retry. If nil, retries start immediately.
+	Backoff Backoff
+	// Decides whether to run the script again after an attempt. If nil, every
+	// attempt that failed is retried.
+	RetryOn func(res *Result) bool
+}
+
+// Backoff returns how long to wait before retry number n, starting from 1.
+type Backoff func(n int) time.Duration
+
+// ConstantBackoff waits d before every retry.
+func ConstantBackoff(d time.Duration) Backoff {
+	return func(n int) time.Duration {
+		return d
+	}
+}
+
+// ExponentialBackoff waits base before the first retry, and twice as long
+// before each following retry, but never longer than max.
+func ExponentialBackoff(base, max time.Duration) Backoff {
+	return func(n int) time.Duration {
+		d := base
+		for i := 1; i < n && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			return max
+		}
+		return d
+	}
+}
+
+// Retries is an Option that runs scripts again according to policy. Retries
+// apply to every method that runs a script to completion, including the
+// generated f, p, and t variants, but not to Lines, Stream, or Pipe, whose
+// output has already been consumed by the time a script fails.
+//
+// Each attempt is traced separately, and output of failed attempts that is
+// connected to the shell's Stdout or Stderr is not taken back. Only the last
+// attempt counts for Must and LastError. If the shell has a Stdin, it is read
+// before the first attempt, and fed to every attempt.
+//
+//   flaky := sh.With(shell.Timeout(10*time.Second), shell.Retries(shell.Retry{
+//     Attempts: 5,
+//     Backoff:  shell.ExponentialBackoff(time.Second, 30*time.Second),
+//   }))
+//   flaky.Run(`curl -fsS https://example.com/healthz`)
+func Retries(policy Retry) Option {
+	return func(sh *Shell) {
+		sh.retry = policy
+	}
+}
+
+// GracePeriod is an Option that sets how long a script has to exit after
+// SIGTERM once its Timeout expires, before it is killed with SIGKILL. The
+// default is DefaultGracePeriod.
+func GracePeriod(d time.Duration) Option {
+	return func(sh *Shell) {
+		sh.gracePeriod = d
+	}
+}
+
+// DefaultGracePeriod is the GracePeriod of a Shell that has none.
+const DefaultGracePeriod = 5 * time.Second
+
+// retryAfter returns true if res, which was attempt number attempt, should be
+// retried.
+func (r Retry) retryAfter(attempt int, res *Result) bool {
+	if attempt >= r.Attempts {
+		return false
+	}
+	if r.RetryOn == nil {
+		return !res.Success()
+	}
+	return r.RetryOn(res)
+}
+
+func (r Retry) backoff(n int) time.Duration {
+	if r.Backoff == nil {
+		return 0
+	}
+	return r.Backoff(n)
+}
+
+// sleep waits for d, or until the shell's context is done. Returns false if
+// the context is done.
+func (sh *Shell) sleep(d time.Duration) bool {
+	var done <-chan struct{}
+	if sh.ctx != nil {
+		done = sh.ctx.Done()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-done:
+		return false
+	}
+}
@@ -0,0 +1,78 @@
+package shell
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "retry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Fails twice, then succeeds.
+	script := `echo x >> count; test $(wc -l < count) -ge 3`
+	rec := &TraceRecorder{}
+	sh := (&Shell{Dir: dir, Trace: rec}).With(Retries(Retry{
+		Attempts: 5,
+		Backoff:  ConstantBackoff(time.Millisecond),
+	}))
+	res := sh.Do(script)
+	if !res.Success() || res.Attempts != 3 {
+		t.Errorf("Do(...) with Retries -> %v after %d attempts, expected success after 3", res.Err(), res.Attempts)
+	}
+	if n := len(rec.Scripts()); n != 3 {
+		t.Errorf("Expected 3 traced attempts, got %d", n)
+	}
+
+	os.Remove(filepath.Join(dir, "count"))
+	sh = sh.With(Retries(Retry{
+		Attempts: 5,
+		RetryOn:  func(res *Result) bool { return res.ExitCode == 7 },
+	}))
+	if sh.Succeeds(script) {
+		t.Errorf("Succeeds(...) should not retry exit status 1 if RetryOn is for 7")
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(time.Second, 5*time.Second)
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, d := range expected {
+		if got := backoff(i + 1); got != d {
+			t.Errorf("backoff(%d) -> %v != %v", i+1, got, d)
+		}
+	}
+}
+
+func TestTimeoutGracePeriod(t *testing.T) {
+	sh := (&Shell{}).With(Timeout(100*time.Millisecond), GracePeriod(100*time.Millisecond))
+
+	// Exits on SIGTERM.
+	res := sh.Do(`sleep 5 & wait`)
+	if !res.TimedOut || res.Signal != syscall.SIGTERM {
+		t.Errorf("Do(`sleep 5 & wait`) -> TimedOut %v, Signal %v, expected SIGTERM", res.TimedOut, res.Signal)
+	}
+
+	// Ignores SIGTERM, so needs SIGKILL. The child sleep holds Stdout open, so
+	// the Do would hang if it were not killed with the whole process group.
+	start := time.Now()
+	res = sh.Do(`trap '' TERM; sleep 5; sleep 5`)
+	if !res.TimedOut || res.Signal != syscall.SIGKILL || time.Since(start) > 2*time.Second {
+		t.Errorf("Do(...) -> TimedOut %v, Signal %v after %v, expected SIGKILL", res.TimedOut, res.Signal, time.Since(start))
+	}
+
+	// Exits on SIGTERM, but leaves a child that ignores it holding Stdout
+	// open, so the child must be killed before the script is reaped.
+	start = time.Now()
+	res = sh.Do(`(trap '' TERM; sleep 5) & wait`)
+	if !res.TimedOut || res.Signal != syscall.SIGTERM || time.Since(start) > 2*time.Second {
+		t.Errorf("Do(...) with a leftover child -> TimedOut %v, Signal %v after %v", res.TimedOut, res.Signal, time.Since(start))
+	}
+}
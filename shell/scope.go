@@ -0,0 +1,113 @@
+package shell
+
+// Derived shells that run scripts in a different directory or environment.
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+)
+
+// Environer provides environment variables for the scripts run by a Shell.
+// Both Vars and *env.Vars implement Environer.
+type Environer interface {
+	// Environ returns variables as "key=value" strings, like os.Environ.
+	Environ() []string
+}
+
+// Environ implements Environer for Vars. Values are converted to strings with
+// ToRaw.
+func (vars Vars) Environ() []string {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	environ := make([]string, len(keys))
+	for i, k := range keys {
+		environ[i] = fmt.Sprintf("%s=%s", k, ToRaw(vars[k]))
+	}
+	return environ
+}
+
+// layeredEnv combines several Environers. Variables from later layers
+// override variables from earlier layers.
+type layeredEnv []Environer
+
+func (layers layeredEnv) Environ() []string {
+	var environ []string
+	for _, layer := range layers {
+		environ = append(environ, layer.Environ()...)
+	}
+	return environ
+}
+
+// Copy returns a new Shell with the same configuration as sh. Unlike With, the
+// copy does not share LastError or background jobs with sh, but it does mask
+// the same secrets. See MaskSecret.
+func (sh *Shell) Copy() *Shell {
+	secrets := sh.secrets()
+	sharedStates.Lock()
+	copied := *sh
+	sharedStates.Unlock()
+	copied.shared = &sharedState{secrets: secrets}
+	return &copied
+}
+
+// WithDir returns a copy of the shell that runs scripts in dir. A relative dir
+// is resolved against sh.Dir, like `cd` would.
+//
+//   src := sh.WithDir("src")
+//   src.Run(`make`)
+func (sh *Shell) WithDir(dir string) Interface {
+	copied := sh.Copy()
+	Dir(dir)(copied)
+	return copied
+}
+
+// InDir calls fn with a copy of the shell that runs scripts in dir. This is
+// like a `( cd dir && ... )` subshell.
+//
+//   sh.InDir("frontend", func(sh shell.Interface) {
+//     sh.Must().Run(`npm install`)
+//     sh.Must().Run(`npm run build`)
+//   })
+func (sh *Shell) InDir(dir string, fn func(sh Interface)) {
+	fn(sh.WithDir(dir))
+}
+
+// WithEnv returns a copy of the shell that adds vars to the environment of
+// scripts, on top of sh.Env. Pass an *env.Vars to export its Locals.
+//
+//   sh.WithEnv(Vars{"KUBECONFIG": path}).Run(`kubectl get pods`)
+func (sh *Shell) WithEnv(vars Environer) Interface {
+	copied := sh.Copy()
+	Env(vars)(copied)
+	return copied
+}
+
+// resolveDir resolves dir against base, like `cd dir` from base would.
+func resolveDir(base, dir string) string {
+	if filepath.IsAbs(dir) || base == "" {
+		return dir
+	}
+	return filepath.Join(base, dir)
+}
+
+// prepare applies the shell's Dir, Env and Stdin to cmd, unless cmd already
+// has its own.
+func (sh *Shell) prepare(cmd *exec.Cmd) *exec.Cmd {
+	if cmd.Stdin == nil {
+		cmd.Stdin = sh.stdin()
+	}
+	if cmd.Dir == "" {
+		cmd.Dir = sh.Dir
+	}
+	if cmd.Env == nil && sh.Env != nil {
+		cmd.Env = append(os.Environ(), sh.Env.Environ()...)
+	}
+	return cmd
+}
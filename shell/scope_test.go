@@ -0,0 +1,58 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWithDir(t *testing.T) {
+	root, err := filepath.EvalSymlinks(os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sh := (&Shell{}).WithDir(root)
+	if out := sh.Out(`pwd`); out != root {
+		t.Errorf("WithDir(%q).Out(`pwd`) -> %q", root, out)
+	}
+
+	var inner string
+	sh.InDir(".", func(sh Interface) {
+		inner = sh.ExecOut("pwd")
+	})
+	if inner != root {
+		t.Errorf("InDir(%q).ExecOut(pwd) -> %q", ".", inner)
+	}
+}
+
+func TestWithEnv(t *testing.T) {
+	sh := (&Shell{}).WithEnv(Vars{"FIRST": "one", "SECOND": 2})
+	sh = sh.WithEnv(Vars{"SECOND": "two"})
+
+	out := sh.Out(`echo "$FIRST $SECOND"`)
+	if out != "one two" {
+		t.Errorf("WithEnv(...).Out(...) -> %q != %q", out, "one two")
+	}
+	if sh.Out(`echo "$HOME"`) != os.Getenv("HOME") {
+		t.Errorf("WithEnv should inherit os.Environ")
+	}
+}
+
+func TestMockShellScope(t *testing.T) {
+	sh := &MockShell{}
+	sh.AddMock(MockCall{Script: "make", Stdout: "built"})
+	sh.AddMock(MockCall{Script: "make", Stdout: "built again"})
+	sh.AddMock(MockCall{Script: "env", Stdout: "A=1"})
+
+	if out := sh.WithDir("src").Out(`make`); out != "built" {
+		t.Errorf("WithDir(src).Out(`make`) -> %q", out)
+	}
+	sh.InDir("src", func(sh Interface) {
+		if out := sh.Out(`make`); out != "built again" {
+			t.Errorf("InDir(src) Out(`make`) -> %q", out)
+		}
+	})
+	if out := sh.WithEnv(Vars{"A": 1}).Out(`env`); out != "A=1" {
+		t.Errorf("WithEnv(...).Out(`env`) -> %q", out)
+	}
+}
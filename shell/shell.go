@@ -6,10 +6,9 @@ package shell
 import (
 	"bytes"
 	"context"
-	"fmt"
 	"io"
-	"os"
 	"os/exec"
+	"time"
 )
 
 // DefaultShell is the default shell used for new Shell instances to run scripts.
@@ -17,30 +16,59 @@ var DefaultShell = []string{"bash", "-c"}
 
 // Shell provides several ways to execute shell scripts. It allows configuring
 // a default Stdout and Stderr for executed scripts. Most Shell methods will
-// either ignore or return if the script results in an exec.ExitError, and will
+// either ignore or return if the script results in an ExitError, and will
 // panic on other errors.
 //
-// A Shell should not be shared between goroutines - it's simple enough to use
-// sh.Copy() to create a new one if needed.
+// A Shell may be shared between goroutines, as long as its fields are not
+// modified while scripts are running. Use With to derive a shell with
+// different options for a particular call.
 type Shell struct {
 	// Eg, []string{"bash", "-c"}
 	DefaultArgs []string
+	// If set, runs scripts instead of DefaultArgs, and decides how Escape
+	// quotes values. See Interpreter.
+	Interpreter *Interpreter
+	// If set, will be connected to the Stdin of executed scripts. See Feed.
+	Stdin io.Reader
 	// If set, Stdout will be connected to any executed script's Stdout if possible.
 	Stdout io.Writer
 	// If set, Stderr will be connected to any executed script's Stderr if possible.
 	Stderr io.Writer
-	// If true, methods will log unexpected errors instead of panicing.
+	// If true, methods will log unexpected errors instead of panicing. Ignored
+	// if the shell has an ErrorPolicy.
 	IgnoreUnexpectedErrors bool
+	// Decides what happens when a script fails. If nil, DefaultErrorPolicy.
+	ErrorPolicy ErrorPolicy
 	// If true, methods will not chomp the last newline of a command's stdout or stderr.
 	PreserveTrailingNewline bool
+	// If set, scripts will run in this directory instead of the current
+	// working directory.
+	Dir string
+	// If set, these variables will be added to the environment of scripts,
+	// overriding any inherited from os.Environ.
+	Env Environer
+	// If set, will be notified of every script run by the shell.
+	Trace Tracer
+	// If set, scripts will be printed instead of run. See DryRun.
+	DryRun *DryRun
 	// If set, will be used to generate a command instead of the default method.
 	MakeCmd func(script string) *exec.Cmd
 	// Will be added to any commands if not nil
 	ctx context.Context
-	// Sometimes useful to reference the status of Succeeds or Cmd invocations
-	lastError *exec.ExitError
-	// Sometimes useful to panic only for one call.
-	panicOnNextError bool
+	// LastError and background jobs, shared with shells derived by With.
+	shared *sharedState
+	// If non-zero, scripts are killed after running this long.
+	timeout time.Duration
+	// If true, Parallel and ForEach stop at the first failure.
+	failFast bool
+	// How to retry failed scripts. See Retries.
+	retry Retry
+	// How long timed out scripts have to exit after SIGTERM.
+	gracePeriod time.Duration
+	// If true, scripts run in their own process group. See ProcessGroup.
+	processGroup bool
+	// If true, scripts start with the Interpreter's Strict code. See StrictMode.
+	strict bool
 }
 
 // WithContext creates a new shell with the given context.
@@ -60,29 +88,45 @@ func WithContext(ctx context.Context) *Shell {
 //
 // @StaticCompose.Inside("formatters")
 func (sh *Shell) Cmd(script string) *exec.Cmd {
-	if len(sh.DefaultArgs) == 0 {
-		sh.DefaultArgs = DefaultShell
+	if sh.MakeCmd != nil {
+		return sh.prepare(sh.MakeCmd(script))
 	}
-	rest := append(sh.DefaultArgs[1:], script)
-	if sh.ctx != nil {
-		return exec.CommandContext(sh.ctx, sh.DefaultArgs[0], rest...)
+	interp := sh.interpreter()
+	if interp.Invoke == ScriptStdin && interp.Run == nil && sh.Stdin != nil {
+		panic("shell: Interpreter reads scripts from Stdin, so the Shell can't have a Stdin")
+	}
+	return sh.prepare(interp.command(sh.ctx, sh.strictScript(interp, script)))
+}
+
+// scriptCmd returns a function that makes a new command for script each time
+// it is called.
+func (sh *Shell) scriptCmd(script string) func() *exec.Cmd {
+	return func() *exec.Cmd {
+		return sh.Cmd(script)
 	}
-	return exec.Command(sh.DefaultArgs[0], rest...)
 }
 
 // Ways to run a script:
 
+// Do runs the script to completion and returns a Result describing the
+// process, including its captured Stdout and Stderr. The other ways to run a
+// script are shortcuts for common uses of Do.
+//
+//   res := sh.Do(`make test`)
+//   fmt.Println(res.ExitCode, res.Duration())
+//
+// @StaticCompose.Inside("formatters")
+func (sh *Shell) Do(script string) *Result {
+	return sh.do(sh.scriptCmd(script), script, nil, nil)
+}
+
 // Out captures the Stdout of a script and returns it as a string, minus the
 // last trailing newline. This is analagous to `$(...)` in Bash. If an error
 // occurs, it will be printed to the default Stderr.
 //
 // @StaticCompose.Inside("formatters")
 func (sh *Shell) Out(script string) string {
-	cmd := sh.Cmd(script)
-	cmd.Stderr = sh.Stderr
-	out, err := cmd.Output()
-	sh.onError(err)
-	return sh.trim(out)
+	return sh.do(sh.scriptCmd(script), script, nil, sh.Stderr).Stdout
 }
 
 // OutStatus captures the Stdout of a script and returns it as a string, minus
@@ -91,11 +135,8 @@ func (sh *Shell) Out(script string) string {
 //
 // @StaticCompose.Inside("formatters")
 func (sh *Shell) OutStatus(script string) (string, error) {
-	cmd := sh.Cmd(script)
-	cmd.Stderr = sh.Stderr
-	out, err := cmd.Output()
-	sh.onError(err)
-	return sh.trim(out), err
+	res := sh.do(sh.scriptCmd(script), script, nil, sh.Stderr)
+	return res.Stdout, res.Err()
 }
 
 // OutErrStatus captures the Stdout and Stderr of a script and returns each as
@@ -104,24 +145,15 @@ func (sh *Shell) OutStatus(script string) (string, error) {
 //
 // @StaticCompose.Inside("formatters")
 func (sh *Shell) OutErrStatus(script string) (string, string, error) {
-	var stderr bytes.Buffer
-	cmd := sh.Cmd(script)
-	cmd.Stderr = &stderr
-	out, status := cmd.Output()
-	sh.onError(status)
-	return sh.trim(out), sh.trim(stderr.Bytes()), status
+	res := sh.do(sh.scriptCmd(script), script, nil, nil)
+	return res.Stdout, res.Stderr, res.Err()
 }
 
 // Run runs the given script to completion.
 //
 // @StaticCompose.Inside("formatters")
 func (sh *Shell) Run(script string) error {
-	cmd := sh.Cmd(script)
-	cmd.Stdout = sh.Stdout
-	cmd.Stderr = sh.Stderr
-	err := cmd.Run()
-	sh.onError(err)
-	return err
+	return sh.do(sh.scriptCmd(script), script, sh.Stdout, sh.Stderr).Err()
 }
 
 // Succeeds runs the script and returns true if the script exited 0, or false
@@ -135,45 +167,55 @@ func (sh *Shell) Succeeds(script string) bool {
 
 // LastError returns the last ExitError of script run. This can be useful for
 // checking the exit code of Succeeds or Out calls. Note that if you share a
-// Shell across several goroutines, the LastError may change unexpectedly.
-func (sh *Shell) LastError() *exec.ExitError {
-	return sh.lastError
+// Shell across several goroutines, the LastError may change unexpectedly;
+// prefer the error returned by each call, or Result.Err.
+func (sh *Shell) LastError() *ExitError {
+	state := sh.state()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return state.lastError
 }
 
-// Must configures the shell to panic if the next script execution exits
-// non-zero.
+// Must returns a derived shell that panics if a script fails. It is shorthand
+// for sh.With(Must).
 //
 //   pid := sh.Must().Out(`cat /var/run/yolo.pid`)
 //   sh.Must().Run(`kill -9 `+pid)
-func (sh *Shell) Must() *Shell {
-	sh.panicOnNextError = true
-	return sh
+//
+// Must used to make sh itself panic if its next script failed. It now leaves
+// sh alone, so calling sh.Must() as a statement, and then a method of sh, no
+// longer panics; call the method on the shell Must returns instead.
+func (sh *Shell) Must() Interface {
+	return sh.With(Must)
 }
 
+// onError records the error of a script in LastError, and applies the shell's
+// ErrorPolicy.
 func (sh *Shell) onError(err error) {
-	defer func() { sh.panicOnNextError = false }()
 	// Update last error
 	if err == nil {
-		sh.lastError = nil
+		sh.setLastError(nil)
 		return
 	}
-	if status, ok := err.(*exec.ExitError); ok {
-		sh.lastError = status
-		if sh.panicOnNextError {
-			panic(status)
-		}
-		return
+	if status, ok := err.(*ExitError); ok {
+		sh.setLastError(status)
+	} else if IsFailure(err) {
+		// A failed Go stage in a Pipeline or bad output is an expected error,
+		// like an exit status, but there is no ExitError to record.
+		sh.setLastError(nil)
 	}
+	sh.errorPolicy().HandleError(err)
+}
 
+// errorPolicy returns the ErrorPolicy of the shell, or the default.
+func (sh *Shell) errorPolicy() ErrorPolicy {
+	if sh.ErrorPolicy != nil {
+		return sh.ErrorPolicy
+	}
 	if sh.IgnoreUnexpectedErrors {
-		if sh.Stderr != nil {
-			fmt.Fprintln(sh.Stderr, err)
-		} else {
-			fmt.Fprintln(os.Stderr, err)
-		}
-		return
+		return printUnexpected(sh.Stderr)
 	}
-	panic(err)
+	return DefaultErrorPolicy
 }
 
 func (sh *Shell) trim(data []byte) string {
@@ -4,167 +4,662 @@ import "os/exec"
 
 // AUTO-GENERATED WITH static_compose [-in . -out shell_format_methods.go]
 
-// Outp is equivalent to sh.Out(ScriptPrint(vs...))
+// ExecCmdp is equivalent to sh.ExecCmd(ArgvPrint(vs...)...)
+func (sh *Shell) ExecCmdp(vs ...interface{}) *exec.Cmd {
+	return sh.ExecCmd(ArgvPrint(vs...)...)
+}
+
+// ExecCmdf is equivalent to sh.ExecCmd(ArgvPrintf(scriptformat, vs...)...)
+func (sh *Shell) ExecCmdf(scriptformat string, vs ...interface{}) *exec.Cmd {
+	return sh.ExecCmd(ArgvPrintf(scriptformat, vs...)...)
+}
+
+// ExecCmdt is equivalent to sh.ExecCmd(ArgvTemplate(template, vars)...)
+func (sh *Shell) ExecCmdt(template string, vars Lookuper) *exec.Cmd {
+	return sh.ExecCmd(ArgvTemplate(template, vars)...)
+}
+
+// ExecDop is equivalent to sh.ExecDo(ArgvPrint(vs...)...)
+func (sh *Shell) ExecDop(vs ...interface{}) *Result {
+	return sh.ExecDo(ArgvPrint(vs...)...)
+}
+
+// ExecDof is equivalent to sh.ExecDo(ArgvPrintf(scriptformat, vs...)...)
+func (sh *Shell) ExecDof(scriptformat string, vs ...interface{}) *Result {
+	return sh.ExecDo(ArgvPrintf(scriptformat, vs...)...)
+}
+
+// ExecDot is equivalent to sh.ExecDo(ArgvTemplate(template, vars)...)
+func (sh *Shell) ExecDot(template string, vars Lookuper) *Result {
+	return sh.ExecDo(ArgvTemplate(template, vars)...)
+}
+
+// Execp is equivalent to sh.Exec(ArgvPrint(vs...)...)
+func (sh *Shell) Execp(vs ...interface{}) error {
+	return sh.Exec(ArgvPrint(vs...)...)
+}
+
+// Execf is equivalent to sh.Exec(ArgvPrintf(scriptformat, vs...)...)
+func (sh *Shell) Execf(scriptformat string, vs ...interface{}) error {
+	return sh.Exec(ArgvPrintf(scriptformat, vs...)...)
+}
+
+// Exect is equivalent to sh.Exec(ArgvTemplate(template, vars)...)
+func (sh *Shell) Exect(template string, vars Lookuper) error {
+	return sh.Exec(ArgvTemplate(template, vars)...)
+}
+
+// ExecOutp is equivalent to sh.ExecOut(ArgvPrint(vs...)...)
+func (sh *Shell) ExecOutp(vs ...interface{}) string {
+	return sh.ExecOut(ArgvPrint(vs...)...)
+}
+
+// ExecOutf is equivalent to sh.ExecOut(ArgvPrintf(scriptformat, vs...)...)
+func (sh *Shell) ExecOutf(scriptformat string, vs ...interface{}) string {
+	return sh.ExecOut(ArgvPrintf(scriptformat, vs...)...)
+}
+
+// ExecOutt is equivalent to sh.ExecOut(ArgvTemplate(template, vars)...)
+func (sh *Shell) ExecOutt(template string, vars Lookuper) string {
+	return sh.ExecOut(ArgvTemplate(template, vars)...)
+}
+
+// ExecOutStatusp is equivalent to sh.ExecOutStatus(ArgvPrint(vs...)...)
+func (sh *Shell) ExecOutStatusp(vs ...interface{}) (string, error) {
+	return sh.ExecOutStatus(ArgvPrint(vs...)...)
+}
+
+// ExecOutStatusf is equivalent to sh.ExecOutStatus(ArgvPrintf(scriptformat, vs...)...)
+func (sh *Shell) ExecOutStatusf(scriptformat string, vs ...interface{}) (string, error) {
+	return sh.ExecOutStatus(ArgvPrintf(scriptformat, vs...)...)
+}
+
+// ExecOutStatust is equivalent to sh.ExecOutStatus(ArgvTemplate(template, vars)...)
+func (sh *Shell) ExecOutStatust(template string, vars Lookuper) (string, error) {
+	return sh.ExecOutStatus(ArgvTemplate(template, vars)...)
+}
+
+// ExecOutErrStatusp is equivalent to sh.ExecOutErrStatus(ArgvPrint(vs...)...)
+func (sh *Shell) ExecOutErrStatusp(vs ...interface{}) (string, string, error) {
+	return sh.ExecOutErrStatus(ArgvPrint(vs...)...)
+}
+
+// ExecOutErrStatusf is equivalent to sh.ExecOutErrStatus(ArgvPrintf(scriptformat, vs...)...)
+func (sh *Shell) ExecOutErrStatusf(scriptformat string, vs ...interface{}) (string, string, error) {
+	return sh.ExecOutErrStatus(ArgvPrintf(scriptformat, vs...)...)
+}
+
+// ExecOutErrStatust is equivalent to sh.ExecOutErrStatus(ArgvTemplate(template, vars)...)
+func (sh *Shell) ExecOutErrStatust(template string, vars Lookuper) (string, string, error) {
+	return sh.ExecOutErrStatus(ArgvTemplate(template, vars)...)
+}
+
+// ExecSucceedsp is equivalent to sh.ExecSucceeds(ArgvPrint(vs...)...)
+func (sh *Shell) ExecSucceedsp(vs ...interface{}) bool {
+	return sh.ExecSucceeds(ArgvPrint(vs...)...)
+}
+
+// ExecSucceedsf is equivalent to sh.ExecSucceeds(ArgvPrintf(scriptformat, vs...)...)
+func (sh *Shell) ExecSucceedsf(scriptformat string, vs ...interface{}) bool {
+	return sh.ExecSucceeds(ArgvPrintf(scriptformat, vs...)...)
+}
+
+// ExecSucceedst is equivalent to sh.ExecSucceeds(ArgvTemplate(template, vars)...)
+func (sh *Shell) ExecSucceedst(template string, vars Lookuper) bool {
+	return sh.ExecSucceeds(ArgvTemplate(template, vars)...)
+}
+
+// OutJSONp is equivalent to sh.OutJSON(sh.ScriptPrint(vs...), v)
+func (sh *Shell) OutJSONp(v interface{}, vs ...interface{}) error {
+	return sh.OutJSON(sh.ScriptPrint(vs...), v)
+}
+
+// OutJSONf is equivalent to sh.OutJSON(sh.ScriptPrintf(scriptformat, vs...), v)
+func (sh *Shell) OutJSONf(v interface{}, scriptformat string, vs ...interface{}) error {
+	return sh.OutJSON(sh.ScriptPrintf(scriptformat, vs...), v)
+}
+
+// OutJSONt is equivalent to sh.OutJSON(sh.ScriptTemplate(template, vars), v)
+func (sh *Shell) OutJSONt(v interface{}, template string, vars Lookuper) error {
+	return sh.OutJSON(sh.ScriptTemplate(template, vars), v)
+}
+
+// OutLinesp is equivalent to sh.OutLines(sh.ScriptPrint(vs...))
+func (sh *Shell) OutLinesp(vs ...interface{}) []string {
+	return sh.OutLines(sh.ScriptPrint(vs...))
+}
+
+// OutLinesf is equivalent to sh.OutLines(sh.ScriptPrintf(scriptformat, vs...))
+func (sh *Shell) OutLinesf(scriptformat string, vs ...interface{}) []string {
+	return sh.OutLines(sh.ScriptPrintf(scriptformat, vs...))
+}
+
+// OutLinest is equivalent to sh.OutLines(sh.ScriptTemplate(template, vars))
+func (sh *Shell) OutLinest(template string, vars Lookuper) []string {
+	return sh.OutLines(sh.ScriptTemplate(template, vars))
+}
+
+// OutFieldsp is equivalent to sh.OutFields(sh.ScriptPrint(vs...), sep)
+func (sh *Shell) OutFieldsp(sep string, vs ...interface{}) [][]string {
+	return sh.OutFields(sh.ScriptPrint(vs...), sep)
+}
+
+// OutFieldsf is equivalent to sh.OutFields(sh.ScriptPrintf(scriptformat, vs...), sep)
+func (sh *Shell) OutFieldsf(sep string, scriptformat string, vs ...interface{}) [][]string {
+	return sh.OutFields(sh.ScriptPrintf(scriptformat, vs...), sep)
+}
+
+// OutFieldst is equivalent to sh.OutFields(sh.ScriptTemplate(template, vars), sep)
+func (sh *Shell) OutFieldst(sep string, template string, vars Lookuper) [][]string {
+	return sh.OutFields(sh.ScriptTemplate(template, vars), sep)
+}
+
+// OutCSVp is equivalent to sh.OutCSV(sh.ScriptPrint(vs...))
+func (sh *Shell) OutCSVp(vs ...interface{}) ([][]string, error) {
+	return sh.OutCSV(sh.ScriptPrint(vs...))
+}
+
+// OutCSVf is equivalent to sh.OutCSV(sh.ScriptPrintf(scriptformat, vs...))
+func (sh *Shell) OutCSVf(scriptformat string, vs ...interface{}) ([][]string, error) {
+	return sh.OutCSV(sh.ScriptPrintf(scriptformat, vs...))
+}
+
+// OutCSVt is equivalent to sh.OutCSV(sh.ScriptTemplate(template, vars))
+func (sh *Shell) OutCSVt(template string, vars Lookuper) ([][]string, error) {
+	return sh.OutCSV(sh.ScriptTemplate(template, vars))
+}
+
+// Startp is equivalent to sh.Start(sh.ScriptPrint(vs...))
+func (sh *Shell) Startp(vs ...interface{}) *Job {
+	return sh.Start(sh.ScriptPrint(vs...))
+}
+
+// Startf is equivalent to sh.Start(sh.ScriptPrintf(scriptformat, vs...))
+func (sh *Shell) Startf(scriptformat string, vs ...interface{}) *Job {
+	return sh.Start(sh.ScriptPrintf(scriptformat, vs...))
+}
+
+// Startt is equivalent to sh.Start(sh.ScriptTemplate(template, vars))
+func (sh *Shell) Startt(template string, vars Lookuper) *Job {
+	return sh.Start(sh.ScriptTemplate(template, vars))
+}
+
+// Cmdp is equivalent to sh.Cmd(sh.ScriptPrint(vs...))
+func (sh *MockShell) Cmdp(vs ...interface{}) *exec.Cmd {
+	return sh.Cmd(sh.ScriptPrint(vs...))
+}
+
+// Cmdf is equivalent to sh.Cmd(sh.ScriptPrintf(scriptformat, vs...))
+func (sh *MockShell) Cmdf(scriptformat string, vs ...interface{}) *exec.Cmd {
+	return sh.Cmd(sh.ScriptPrintf(scriptformat, vs...))
+}
+
+// Cmdt is equivalent to sh.Cmd(sh.ScriptTemplate(template, vars))
+func (sh *MockShell) Cmdt(template string, vars Lookuper) *exec.Cmd {
+	return sh.Cmd(sh.ScriptTemplate(template, vars))
+}
+
+// Dop is equivalent to sh.Do(sh.ScriptPrint(vs...))
+func (sh *MockShell) Dop(vs ...interface{}) *Result {
+	return sh.Do(sh.ScriptPrint(vs...))
+}
+
+// Dof is equivalent to sh.Do(sh.ScriptPrintf(scriptformat, vs...))
+func (sh *MockShell) Dof(scriptformat string, vs ...interface{}) *Result {
+	return sh.Do(sh.ScriptPrintf(scriptformat, vs...))
+}
+
+// Dot is equivalent to sh.Do(sh.ScriptTemplate(template, vars))
+func (sh *MockShell) Dot(template string, vars Lookuper) *Result {
+	return sh.Do(sh.ScriptTemplate(template, vars))
+}
+
+// Outp is equivalent to sh.Out(sh.ScriptPrint(vs...))
 func (sh *MockShell) Outp(vs ...interface{}) string {
-	return sh.Out(ScriptPrint(vs...))
+	return sh.Out(sh.ScriptPrint(vs...))
 }
 
-// Outf is equivalent to sh.Out(ScriptPrintf(scriptformat, vs...))
+// Outf is equivalent to sh.Out(sh.ScriptPrintf(scriptformat, vs...))
 func (sh *MockShell) Outf(scriptformat string, vs ...interface{}) string {
-	return sh.Out(ScriptPrintf(scriptformat, vs...))
+	return sh.Out(sh.ScriptPrintf(scriptformat, vs...))
 }
 
-// Outt is equivalent to sh.Out(ScriptTemplate(template, vars))
+// Outt is equivalent to sh.Out(sh.ScriptTemplate(template, vars))
 func (sh *MockShell) Outt(template string, vars Lookuper) string {
-	return sh.Out(ScriptTemplate(template, vars))
+	return sh.Out(sh.ScriptTemplate(template, vars))
 }
 
-// OutStatusp is equivalent to sh.OutStatus(ScriptPrint(vs...))
+// OutStatusp is equivalent to sh.OutStatus(sh.ScriptPrint(vs...))
 func (sh *MockShell) OutStatusp(vs ...interface{}) (string, error) {
-	return sh.OutStatus(ScriptPrint(vs...))
+	return sh.OutStatus(sh.ScriptPrint(vs...))
 }
 
-// OutStatusf is equivalent to sh.OutStatus(ScriptPrintf(scriptformat, vs...))
+// OutStatusf is equivalent to sh.OutStatus(sh.ScriptPrintf(scriptformat, vs...))
 func (sh *MockShell) OutStatusf(scriptformat string, vs ...interface{}) (string, error) {
-	return sh.OutStatus(ScriptPrintf(scriptformat, vs...))
+	return sh.OutStatus(sh.ScriptPrintf(scriptformat, vs...))
 }
 
-// OutStatust is equivalent to sh.OutStatus(ScriptTemplate(template, vars))
+// OutStatust is equivalent to sh.OutStatus(sh.ScriptTemplate(template, vars))
 func (sh *MockShell) OutStatust(template string, vars Lookuper) (string, error) {
-	return sh.OutStatus(ScriptTemplate(template, vars))
+	return sh.OutStatus(sh.ScriptTemplate(template, vars))
 }
 
-// OutErrStatusp is equivalent to sh.OutErrStatus(ScriptPrint(vs...))
+// OutErrStatusp is equivalent to sh.OutErrStatus(sh.ScriptPrint(vs...))
 func (sh *MockShell) OutErrStatusp(vs ...interface{}) (string, string, error) {
-	return sh.OutErrStatus(ScriptPrint(vs...))
+	return sh.OutErrStatus(sh.ScriptPrint(vs...))
 }
 
-// OutErrStatusf is equivalent to sh.OutErrStatus(ScriptPrintf(scriptformat, vs...))
+// OutErrStatusf is equivalent to sh.OutErrStatus(sh.ScriptPrintf(scriptformat, vs...))
 func (sh *MockShell) OutErrStatusf(scriptformat string, vs ...interface{}) (string, string, error) {
-	return sh.OutErrStatus(ScriptPrintf(scriptformat, vs...))
+	return sh.OutErrStatus(sh.ScriptPrintf(scriptformat, vs...))
 }
 
-// OutErrStatust is equivalent to sh.OutErrStatus(ScriptTemplate(template, vars))
+// OutErrStatust is equivalent to sh.OutErrStatus(sh.ScriptTemplate(template, vars))
 func (sh *MockShell) OutErrStatust(template string, vars Lookuper) (string, string, error) {
-	return sh.OutErrStatus(ScriptTemplate(template, vars))
+	return sh.OutErrStatus(sh.ScriptTemplate(template, vars))
 }
 
-// Runp is equivalent to sh.Run(ScriptPrint(vs...))
+// Runp is equivalent to sh.Run(sh.ScriptPrint(vs...))
 func (sh *MockShell) Runp(vs ...interface{}) error {
-	return sh.Run(ScriptPrint(vs...))
+	return sh.Run(sh.ScriptPrint(vs...))
 }
 
-// Runf is equivalent to sh.Run(ScriptPrintf(scriptformat, vs...))
+// Runf is equivalent to sh.Run(sh.ScriptPrintf(scriptformat, vs...))
 func (sh *MockShell) Runf(scriptformat string, vs ...interface{}) error {
-	return sh.Run(ScriptPrintf(scriptformat, vs...))
+	return sh.Run(sh.ScriptPrintf(scriptformat, vs...))
 }
 
-// Runt is equivalent to sh.Run(ScriptTemplate(template, vars))
+// Runt is equivalent to sh.Run(sh.ScriptTemplate(template, vars))
 func (sh *MockShell) Runt(template string, vars Lookuper) error {
-	return sh.Run(ScriptTemplate(template, vars))
+	return sh.Run(sh.ScriptTemplate(template, vars))
 }
 
-// Succeedsp is equivalent to sh.Succeeds(ScriptPrint(vs...))
+// Succeedsp is equivalent to sh.Succeeds(sh.ScriptPrint(vs...))
 func (sh *MockShell) Succeedsp(vs ...interface{}) bool {
-	return sh.Succeeds(ScriptPrint(vs...))
+	return sh.Succeeds(sh.ScriptPrint(vs...))
 }
 
-// Succeedsf is equivalent to sh.Succeeds(ScriptPrintf(scriptformat, vs...))
+// Succeedsf is equivalent to sh.Succeeds(sh.ScriptPrintf(scriptformat, vs...))
 func (sh *MockShell) Succeedsf(scriptformat string, vs ...interface{}) bool {
-	return sh.Succeeds(ScriptPrintf(scriptformat, vs...))
+	return sh.Succeeds(sh.ScriptPrintf(scriptformat, vs...))
 }
 
-// Succeedst is equivalent to sh.Succeeds(ScriptTemplate(template, vars))
+// Succeedst is equivalent to sh.Succeeds(sh.ScriptTemplate(template, vars))
 func (sh *MockShell) Succeedst(template string, vars Lookuper) bool {
-	return sh.Succeeds(ScriptTemplate(template, vars))
+	return sh.Succeeds(sh.ScriptTemplate(template, vars))
+}
+
+// Linesp is equivalent to sh.Lines(sh.ScriptPrint(vs...), fn)
+func (sh *MockShell) Linesp(fn func(line string) error, vs ...interface{}) error {
+	return sh.Lines(sh.ScriptPrint(vs...), fn)
+}
+
+// Linesf is equivalent to sh.Lines(sh.ScriptPrintf(scriptformat, vs...), fn)
+func (sh *MockShell) Linesf(fn func(line string) error, scriptformat string, vs ...interface{}) error {
+	return sh.Lines(sh.ScriptPrintf(scriptformat, vs...), fn)
+}
+
+// Linest is equivalent to sh.Lines(sh.ScriptTemplate(template, vars), fn)
+func (sh *MockShell) Linest(fn func(line string) error, template string, vars Lookuper) error {
+	return sh.Lines(sh.ScriptTemplate(template, vars), fn)
+}
+
+// Streamp is equivalent to sh.Stream(sh.ScriptPrint(vs...))
+func (sh *MockShell) Streamp(vs ...interface{}) (<-chan Line, *Result) {
+	return sh.Stream(sh.ScriptPrint(vs...))
+}
+
+// Streamf is equivalent to sh.Stream(sh.ScriptPrintf(scriptformat, vs...))
+func (sh *MockShell) Streamf(scriptformat string, vs ...interface{}) (<-chan Line, *Result) {
+	return sh.Stream(sh.ScriptPrintf(scriptformat, vs...))
+}
+
+// Streamt is equivalent to sh.Stream(sh.ScriptTemplate(template, vars))
+func (sh *MockShell) Streamt(template string, vars Lookuper) (<-chan Line, *Result) {
+	return sh.Stream(sh.ScriptTemplate(template, vars))
+}
+
+// OutJSONp is equivalent to sh.OutJSON(sh.ScriptPrint(vs...), v)
+func (sh *MockShell) OutJSONp(v interface{}, vs ...interface{}) error {
+	return sh.OutJSON(sh.ScriptPrint(vs...), v)
+}
+
+// OutJSONf is equivalent to sh.OutJSON(sh.ScriptPrintf(scriptformat, vs...), v)
+func (sh *MockShell) OutJSONf(v interface{}, scriptformat string, vs ...interface{}) error {
+	return sh.OutJSON(sh.ScriptPrintf(scriptformat, vs...), v)
+}
+
+// OutJSONt is equivalent to sh.OutJSON(sh.ScriptTemplate(template, vars), v)
+func (sh *MockShell) OutJSONt(v interface{}, template string, vars Lookuper) error {
+	return sh.OutJSON(sh.ScriptTemplate(template, vars), v)
+}
+
+// OutLinesp is equivalent to sh.OutLines(sh.ScriptPrint(vs...))
+func (sh *MockShell) OutLinesp(vs ...interface{}) []string {
+	return sh.OutLines(sh.ScriptPrint(vs...))
+}
+
+// OutLinesf is equivalent to sh.OutLines(sh.ScriptPrintf(scriptformat, vs...))
+func (sh *MockShell) OutLinesf(scriptformat string, vs ...interface{}) []string {
+	return sh.OutLines(sh.ScriptPrintf(scriptformat, vs...))
+}
+
+// OutLinest is equivalent to sh.OutLines(sh.ScriptTemplate(template, vars))
+func (sh *MockShell) OutLinest(template string, vars Lookuper) []string {
+	return sh.OutLines(sh.ScriptTemplate(template, vars))
+}
+
+// OutFieldsp is equivalent to sh.OutFields(sh.ScriptPrint(vs...), sep)
+func (sh *MockShell) OutFieldsp(sep string, vs ...interface{}) [][]string {
+	return sh.OutFields(sh.ScriptPrint(vs...), sep)
+}
+
+// OutFieldsf is equivalent to sh.OutFields(sh.ScriptPrintf(scriptformat, vs...), sep)
+func (sh *MockShell) OutFieldsf(sep string, scriptformat string, vs ...interface{}) [][]string {
+	return sh.OutFields(sh.ScriptPrintf(scriptformat, vs...), sep)
+}
+
+// OutFieldst is equivalent to sh.OutFields(sh.ScriptTemplate(template, vars), sep)
+func (sh *MockShell) OutFieldst(sep string, template string, vars Lookuper) [][]string {
+	return sh.OutFields(sh.ScriptTemplate(template, vars), sep)
+}
+
+// OutCSVp is equivalent to sh.OutCSV(sh.ScriptPrint(vs...))
+func (sh *MockShell) OutCSVp(vs ...interface{}) ([][]string, error) {
+	return sh.OutCSV(sh.ScriptPrint(vs...))
+}
+
+// OutCSVf is equivalent to sh.OutCSV(sh.ScriptPrintf(scriptformat, vs...))
+func (sh *MockShell) OutCSVf(scriptformat string, vs ...interface{}) ([][]string, error) {
+	return sh.OutCSV(sh.ScriptPrintf(scriptformat, vs...))
+}
+
+// OutCSVt is equivalent to sh.OutCSV(sh.ScriptTemplate(template, vars))
+func (sh *MockShell) OutCSVt(template string, vars Lookuper) ([][]string, error) {
+	return sh.OutCSV(sh.ScriptTemplate(template, vars))
+}
+
+// Startp is equivalent to sh.Start(sh.ScriptPrint(vs...))
+func (sh *MockShell) Startp(vs ...interface{}) *Job {
+	return sh.Start(sh.ScriptPrint(vs...))
+}
+
+// Startf is equivalent to sh.Start(sh.ScriptPrintf(scriptformat, vs...))
+func (sh *MockShell) Startf(scriptformat string, vs ...interface{}) *Job {
+	return sh.Start(sh.ScriptPrintf(scriptformat, vs...))
 }
 
-// Cmdp is equivalent to sh.Cmd(ScriptPrint(vs...))
+// Startt is equivalent to sh.Start(sh.ScriptTemplate(template, vars))
+func (sh *MockShell) Startt(template string, vars Lookuper) *Job {
+	return sh.Start(sh.ScriptTemplate(template, vars))
+}
+
+// ExecCmdp is equivalent to sh.ExecCmd(ArgvPrint(vs...)...)
+func (sh *MockShell) ExecCmdp(vs ...interface{}) *exec.Cmd {
+	return sh.ExecCmd(ArgvPrint(vs...)...)
+}
+
+// ExecCmdf is equivalent to sh.ExecCmd(ArgvPrintf(scriptformat, vs...)...)
+func (sh *MockShell) ExecCmdf(scriptformat string, vs ...interface{}) *exec.Cmd {
+	return sh.ExecCmd(ArgvPrintf(scriptformat, vs...)...)
+}
+
+// ExecCmdt is equivalent to sh.ExecCmd(ArgvTemplate(template, vars)...)
+func (sh *MockShell) ExecCmdt(template string, vars Lookuper) *exec.Cmd {
+	return sh.ExecCmd(ArgvTemplate(template, vars)...)
+}
+
+// ExecDop is equivalent to sh.ExecDo(ArgvPrint(vs...)...)
+func (sh *MockShell) ExecDop(vs ...interface{}) *Result {
+	return sh.ExecDo(ArgvPrint(vs...)...)
+}
+
+// ExecDof is equivalent to sh.ExecDo(ArgvPrintf(scriptformat, vs...)...)
+func (sh *MockShell) ExecDof(scriptformat string, vs ...interface{}) *Result {
+	return sh.ExecDo(ArgvPrintf(scriptformat, vs...)...)
+}
+
+// ExecDot is equivalent to sh.ExecDo(ArgvTemplate(template, vars)...)
+func (sh *MockShell) ExecDot(template string, vars Lookuper) *Result {
+	return sh.ExecDo(ArgvTemplate(template, vars)...)
+}
+
+// Execp is equivalent to sh.Exec(ArgvPrint(vs...)...)
+func (sh *MockShell) Execp(vs ...interface{}) error {
+	return sh.Exec(ArgvPrint(vs...)...)
+}
+
+// Execf is equivalent to sh.Exec(ArgvPrintf(scriptformat, vs...)...)
+func (sh *MockShell) Execf(scriptformat string, vs ...interface{}) error {
+	return sh.Exec(ArgvPrintf(scriptformat, vs...)...)
+}
+
+// Exect is equivalent to sh.Exec(ArgvTemplate(template, vars)...)
+func (sh *MockShell) Exect(template string, vars Lookuper) error {
+	return sh.Exec(ArgvTemplate(template, vars)...)
+}
+
+// ExecOutp is equivalent to sh.ExecOut(ArgvPrint(vs...)...)
+func (sh *MockShell) ExecOutp(vs ...interface{}) string {
+	return sh.ExecOut(ArgvPrint(vs...)...)
+}
+
+// ExecOutf is equivalent to sh.ExecOut(ArgvPrintf(scriptformat, vs...)...)
+func (sh *MockShell) ExecOutf(scriptformat string, vs ...interface{}) string {
+	return sh.ExecOut(ArgvPrintf(scriptformat, vs...)...)
+}
+
+// ExecOutt is equivalent to sh.ExecOut(ArgvTemplate(template, vars)...)
+func (sh *MockShell) ExecOutt(template string, vars Lookuper) string {
+	return sh.ExecOut(ArgvTemplate(template, vars)...)
+}
+
+// ExecOutStatusp is equivalent to sh.ExecOutStatus(ArgvPrint(vs...)...)
+func (sh *MockShell) ExecOutStatusp(vs ...interface{}) (string, error) {
+	return sh.ExecOutStatus(ArgvPrint(vs...)...)
+}
+
+// ExecOutStatusf is equivalent to sh.ExecOutStatus(ArgvPrintf(scriptformat, vs...)...)
+func (sh *MockShell) ExecOutStatusf(scriptformat string, vs ...interface{}) (string, error) {
+	return sh.ExecOutStatus(ArgvPrintf(scriptformat, vs...)...)
+}
+
+// ExecOutStatust is equivalent to sh.ExecOutStatus(ArgvTemplate(template, vars)...)
+func (sh *MockShell) ExecOutStatust(template string, vars Lookuper) (string, error) {
+	return sh.ExecOutStatus(ArgvTemplate(template, vars)...)
+}
+
+// ExecOutErrStatusp is equivalent to sh.ExecOutErrStatus(ArgvPrint(vs...)...)
+func (sh *MockShell) ExecOutErrStatusp(vs ...interface{}) (string, string, error) {
+	return sh.ExecOutErrStatus(ArgvPrint(vs...)...)
+}
+
+// ExecOutErrStatusf is equivalent to sh.ExecOutErrStatus(ArgvPrintf(scriptformat, vs...)...)
+func (sh *MockShell) ExecOutErrStatusf(scriptformat string, vs ...interface{}) (string, string, error) {
+	return sh.ExecOutErrStatus(ArgvPrintf(scriptformat, vs...)...)
+}
+
+// ExecOutErrStatust is equivalent to sh.ExecOutErrStatus(ArgvTemplate(template, vars)...)
+func (sh *MockShell) ExecOutErrStatust(template string, vars Lookuper) (string, string, error) {
+	return sh.ExecOutErrStatus(ArgvTemplate(template, vars)...)
+}
+
+// ExecSucceedsp is equivalent to sh.ExecSucceeds(ArgvPrint(vs...)...)
+func (sh *MockShell) ExecSucceedsp(vs ...interface{}) bool {
+	return sh.ExecSucceeds(ArgvPrint(vs...)...)
+}
+
+// ExecSucceedsf is equivalent to sh.ExecSucceeds(ArgvPrintf(scriptformat, vs...)...)
+func (sh *MockShell) ExecSucceedsf(scriptformat string, vs ...interface{}) bool {
+	return sh.ExecSucceeds(ArgvPrintf(scriptformat, vs...)...)
+}
+
+// ExecSucceedst is equivalent to sh.ExecSucceeds(ArgvTemplate(template, vars)...)
+func (sh *MockShell) ExecSucceedst(template string, vars Lookuper) bool {
+	return sh.ExecSucceeds(ArgvTemplate(template, vars)...)
+}
+
+// OutInp is equivalent to sh.OutIn(sh.ScriptPrint(vs...), input)
+func (sh *MockShell) OutInp(input string, vs ...interface{}) string {
+	return sh.OutIn(sh.ScriptPrint(vs...), input)
+}
+
+// OutInf is equivalent to sh.OutIn(sh.ScriptPrintf(scriptformat, vs...), input)
+func (sh *MockShell) OutInf(input string, scriptformat string, vs ...interface{}) string {
+	return sh.OutIn(sh.ScriptPrintf(scriptformat, vs...), input)
+}
+
+// OutInt is equivalent to sh.OutIn(sh.ScriptTemplate(template, vars), input)
+func (sh *MockShell) OutInt(input string, template string, vars Lookuper) string {
+	return sh.OutIn(sh.ScriptTemplate(template, vars), input)
+}
+
+// Cmdp is equivalent to sh.Cmd(sh.ScriptPrint(vs...))
 func (sh *Shell) Cmdp(vs ...interface{}) *exec.Cmd {
-	return sh.Cmd(ScriptPrint(vs...))
+	return sh.Cmd(sh.ScriptPrint(vs...))
 }
 
-// Cmdf is equivalent to sh.Cmd(ScriptPrintf(scriptformat, vs...))
+// Cmdf is equivalent to sh.Cmd(sh.ScriptPrintf(scriptformat, vs...))
 func (sh *Shell) Cmdf(scriptformat string, vs ...interface{}) *exec.Cmd {
-	return sh.Cmd(ScriptPrintf(scriptformat, vs...))
+	return sh.Cmd(sh.ScriptPrintf(scriptformat, vs...))
 }
 
-// Cmdt is equivalent to sh.Cmd(ScriptTemplate(template, vars))
+// Cmdt is equivalent to sh.Cmd(sh.ScriptTemplate(template, vars))
 func (sh *Shell) Cmdt(template string, vars Lookuper) *exec.Cmd {
-	return sh.Cmd(ScriptTemplate(template, vars))
+	return sh.Cmd(sh.ScriptTemplate(template, vars))
 }
 
-// Outp is equivalent to sh.Out(ScriptPrint(vs...))
+// Dop is equivalent to sh.Do(sh.ScriptPrint(vs...))
+func (sh *Shell) Dop(vs ...interface{}) *Result {
+	return sh.Do(sh.ScriptPrint(vs...))
+}
+
+// Dof is equivalent to sh.Do(sh.ScriptPrintf(scriptformat, vs...))
+func (sh *Shell) Dof(scriptformat string, vs ...interface{}) *Result {
+	return sh.Do(sh.ScriptPrintf(scriptformat, vs...))
+}
+
+// Dot is equivalent to sh.Do(sh.ScriptTemplate(template, vars))
+func (sh *Shell) Dot(template string, vars Lookuper) *Result {
+	return sh.Do(sh.ScriptTemplate(template, vars))
+}
+
+// Outp is equivalent to sh.Out(sh.ScriptPrint(vs...))
 func (sh *Shell) Outp(vs ...interface{}) string {
-	return sh.Out(ScriptPrint(vs...))
+	return sh.Out(sh.ScriptPrint(vs...))
 }
 
-// Outf is equivalent to sh.Out(ScriptPrintf(scriptformat, vs...))
+// Outf is equivalent to sh.Out(sh.ScriptPrintf(scriptformat, vs...))
 func (sh *Shell) Outf(scriptformat string, vs ...interface{}) string {
-	return sh.Out(ScriptPrintf(scriptformat, vs...))
+	return sh.Out(sh.ScriptPrintf(scriptformat, vs...))
 }
 
-// Outt is equivalent to sh.Out(ScriptTemplate(template, vars))
+// Outt is equivalent to sh.Out(sh.ScriptTemplate(template, vars))
 func (sh *Shell) Outt(template string, vars Lookuper) string {
-	return sh.Out(ScriptTemplate(template, vars))
+	return sh.Out(sh.ScriptTemplate(template, vars))
 }
 
-// OutStatusp is equivalent to sh.OutStatus(ScriptPrint(vs...))
+// OutStatusp is equivalent to sh.OutStatus(sh.ScriptPrint(vs...))
 func (sh *Shell) OutStatusp(vs ...interface{}) (string, error) {
-	return sh.OutStatus(ScriptPrint(vs...))
+	return sh.OutStatus(sh.ScriptPrint(vs...))
 }
 
-// OutStatusf is equivalent to sh.OutStatus(ScriptPrintf(scriptformat, vs...))
+// OutStatusf is equivalent to sh.OutStatus(sh.ScriptPrintf(scriptformat, vs...))
 func (sh *Shell) OutStatusf(scriptformat string, vs ...interface{}) (string, error) {
-	return sh.OutStatus(ScriptPrintf(scriptformat, vs...))
+	return sh.OutStatus(sh.ScriptPrintf(scriptformat, vs...))
 }
 
-// OutStatust is equivalent to sh.OutStatus(ScriptTemplate(template, vars))
+// OutStatust is equivalent to sh.OutStatus(sh.ScriptTemplate(template, vars))
 func (sh *Shell) OutStatust(template string, vars Lookuper) (string, error) {
-	return sh.OutStatus(ScriptTemplate(template, vars))
+	return sh.OutStatus(sh.ScriptTemplate(template, vars))
 }
 
-// OutErrStatusp is equivalent to sh.OutErrStatus(ScriptPrint(vs...))
+// OutErrStatusp is equivalent to sh.OutErrStatus(sh.ScriptPrint(vs...))
 func (sh *Shell) OutErrStatusp(vs ...interface{}) (string, string, error) {
-	return sh.OutErrStatus(ScriptPrint(vs...))
+	return sh.OutErrStatus(sh.ScriptPrint(vs...))
 }
 
-// OutErrStatusf is equivalent to sh.OutErrStatus(ScriptPrintf(scriptformat, vs...))
+// OutErrStatusf is equivalent to sh.OutErrStatus(sh.ScriptPrintf(scriptformat, vs...))
 func (sh *Shell) OutErrStatusf(scriptformat string, vs ...interface{}) (string, string, error) {
-	return sh.OutErrStatus(ScriptPrintf(scriptformat, vs...))
+	return sh.OutErrStatus(sh.ScriptPrintf(scriptformat, vs...))
 }
 
-// OutErrStatust is equivalent to sh.OutErrStatus(ScriptTemplate(template, vars))
+// OutErrStatust is equivalent to sh.OutErrStatus(sh.ScriptTemplate(template, vars))
 func (sh *Shell) OutErrStatust(template string, vars Lookuper) (string, string, error) {
-	return sh.OutErrStatus(ScriptTemplate(template, vars))
+	return sh.OutErrStatus(sh.ScriptTemplate(template, vars))
 }
 
-// Runp is equivalent to sh.Run(ScriptPrint(vs...))
+// Runp is equivalent to sh.Run(sh.ScriptPrint(vs...))
 func (sh *Shell) Runp(vs ...interface{}) error {
-	return sh.Run(ScriptPrint(vs...))
+	return sh.Run(sh.ScriptPrint(vs...))
 }
 
-// Runf is equivalent to sh.Run(ScriptPrintf(scriptformat, vs...))
+// Runf is equivalent to sh.Run(sh.ScriptPrintf(scriptformat, vs...))
 func (sh *Shell) Runf(scriptformat string, vs ...interface{}) error {
-	return sh.Run(ScriptPrintf(scriptformat, vs...))
+	return sh.Run(sh.ScriptPrintf(scriptformat, vs...))
 }
 
-// Runt is equivalent to sh.Run(ScriptTemplate(template, vars))
+// Runt is equivalent to sh.Run(sh.ScriptTemplate(template, vars))
 func (sh *Shell) Runt(template string, vars Lookuper) error {
-	return sh.Run(ScriptTemplate(template, vars))
+	return sh.Run(sh.ScriptTemplate(template, vars))
 }
 
-// Succeedsp is equivalent to sh.Succeeds(ScriptPrint(vs...))
+// Succeedsp is equivalent to sh.Succeeds(sh.ScriptPrint(vs...))
 func (sh *Shell) Succeedsp(vs ...interface{}) bool {
-	return sh.Succeeds(ScriptPrint(vs...))
+	return sh.Succeeds(sh.ScriptPrint(vs...))
 }
 
-// Succeedsf is equivalent to sh.Succeeds(ScriptPrintf(scriptformat, vs...))
+// Succeedsf is equivalent to sh.Succeeds(sh.ScriptPrintf(scriptformat, vs...))
 func (sh *Shell) Succeedsf(scriptformat string, vs ...interface{}) bool {
-	return sh.Succeeds(ScriptPrintf(scriptformat, vs...))
+	return sh.Succeeds(sh.ScriptPrintf(scriptformat, vs...))
 }
 
-// Succeedst is equivalent to sh.Succeeds(ScriptTemplate(template, vars))
+// Succeedst is equivalent to sh.Succeeds(sh.ScriptTemplate(template, vars))
 func (sh *Shell) Succeedst(template string, vars Lookuper) bool {
-	return sh.Succeeds(ScriptTemplate(template, vars))
+	return sh.Succeeds(sh.ScriptTemplate(template, vars))
+}
+
+// OutInp is equivalent to sh.OutIn(sh.ScriptPrint(vs...), input)
+func (sh *Shell) OutInp(input string, vs ...interface{}) string {
+	return sh.OutIn(sh.ScriptPrint(vs...), input)
+}
+
+// OutInf is equivalent to sh.OutIn(sh.ScriptPrintf(scriptformat, vs...), input)
+func (sh *Shell) OutInf(input string, scriptformat string, vs ...interface{}) string {
+	return sh.OutIn(sh.ScriptPrintf(scriptformat, vs...), input)
+}
+
+// OutInt is equivalent to sh.OutIn(sh.ScriptTemplate(template, vars), input)
+func (sh *Shell) OutInt(input string, template string, vars Lookuper) string {
+	return sh.OutIn(sh.ScriptTemplate(template, vars), input)
+}
+
+// Linesp is equivalent to sh.Lines(sh.ScriptPrint(vs...), fn)
+func (sh *Shell) Linesp(fn func(line string) error, vs ...interface{}) error {
+	return sh.Lines(sh.ScriptPrint(vs...), fn)
+}
+
+// Linesf is equivalent to sh.Lines(sh.ScriptPrintf(scriptformat, vs...), fn)
+func (sh *Shell) Linesf(fn func(line string) error, scriptformat string, vs ...interface{}) error {
+	return sh.Lines(sh.ScriptPrintf(scriptformat, vs...), fn)
+}
+
+// Linest is equivalent to sh.Lines(sh.ScriptTemplate(template, vars), fn)
+func (sh *Shell) Linest(fn func(line string) error, template string, vars Lookuper) error {
+	return sh.Lines(sh.ScriptTemplate(template, vars), fn)
+}
+
+// Streamp is equivalent to sh.Stream(sh.ScriptPrint(vs...))
+func (sh *Shell) Streamp(vs ...interface{}) (<-chan Line, *Result) {
+	return sh.Stream(sh.ScriptPrint(vs...))
+}
+
+// Streamf is equivalent to sh.Stream(sh.ScriptPrintf(scriptformat, vs...))
+func (sh *Shell) Streamf(scriptformat string, vs ...interface{}) (<-chan Line, *Result) {
+	return sh.Stream(sh.ScriptPrintf(scriptformat, vs...))
+}
+
+// Streamt is equivalent to sh.Stream(sh.ScriptTemplate(template, vars))
+func (sh *Shell) Streamt(template string, vars Lookuper) (<-chan Line, *Result) {
+	return sh.Stream(sh.ScriptTemplate(template, vars))
 }
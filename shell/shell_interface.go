@@ -3,6 +3,7 @@
 package shell
 
 import (
+	"io"
 	"os/exec"
 )
 
@@ -12,13 +13,80 @@ type Interface interface {
 	Cmdf(string, ...interface{}) *exec.Cmd
 	Cmdp(...interface{}) *exec.Cmd
 	Cmdt(string, Lookuper) *exec.Cmd
-	LastError() *exec.ExitError
-	Must() *Shell
+	Check(string) error
+	Copy() *Shell
+	Do(string) *Result
+	Dof(string, ...interface{}) *Result
+	Dop(...interface{}) *Result
+	Dot(string, Lookuper) *Result
+	Escape(interface{}) Raw
+	Exec(...string) error
+	ExecCmd(...string) *exec.Cmd
+	ExecCmdf(string, ...interface{}) *exec.Cmd
+	ExecCmdp(...interface{}) *exec.Cmd
+	ExecCmdt(string, Lookuper) *exec.Cmd
+	ExecDo(...string) *Result
+	ExecDof(string, ...interface{}) *Result
+	ExecDop(...interface{}) *Result
+	ExecDot(string, Lookuper) *Result
+	ExecOut(...string) string
+	ExecOutErrStatus(...string) (string, string, error)
+	ExecOutErrStatusf(string, ...interface{}) (string, string, error)
+	ExecOutErrStatusp(...interface{}) (string, string, error)
+	ExecOutErrStatust(string, Lookuper) (string, string, error)
+	ExecOutStatus(...string) (string, error)
+	ExecOutStatusf(string, ...interface{}) (string, error)
+	ExecOutStatusp(...interface{}) (string, error)
+	ExecOutStatust(string, Lookuper) (string, error)
+	ExecOutf(string, ...interface{}) string
+	ExecOutp(...interface{}) string
+	ExecOutt(string, Lookuper) string
+	ExecSucceeds(...string) bool
+	ExecSucceedsf(string, ...interface{}) bool
+	ExecSucceedsp(...interface{}) bool
+	ExecSucceedst(string, Lookuper) bool
+	Execf(string, ...interface{}) error
+	Execp(...interface{}) error
+	Exect(string, Lookuper) error
+	Feed(io.Reader) Interface
+	FeedJSON(interface{}) Interface
+	FeedString(string) Interface
+	ForEach([]string, int, func(sh Interface, item string) error) error
+	InDir(string, func(sh Interface))
+	Jobs() []*Job
+	KillJobs()
+	LastError() *ExitError
+	MaskSecret(string)
+	Lines(string, func(line string) error) error
+	Linesf(func(line string) error, string, ...interface{}) error
+	Linesp(func(line string) error, ...interface{}) error
+	Linest(func(line string) error, string, Lookuper) error
+	Must() Interface
 	Out(string) string
+	OutCSV(string) ([][]string, error)
+	OutCSVf(string, ...interface{}) ([][]string, error)
+	OutCSVp(...interface{}) ([][]string, error)
+	OutCSVt(string, Lookuper) ([][]string, error)
 	OutErrStatus(string) (string, string, error)
 	OutErrStatusf(string, ...interface{}) (string, string, error)
 	OutErrStatusp(...interface{}) (string, string, error)
 	OutErrStatust(string, Lookuper) (string, string, error)
+	OutFields(string, string) [][]string
+	OutFieldsf(string, string, ...interface{}) [][]string
+	OutFieldsp(string, ...interface{}) [][]string
+	OutFieldst(string, string, Lookuper) [][]string
+	OutIn(string, string) string
+	OutInf(string, string, ...interface{}) string
+	OutInp(string, ...interface{}) string
+	OutInt(string, string, Lookuper) string
+	OutJSON(string, interface{}) error
+	OutJSONf(interface{}, string, ...interface{}) error
+	OutJSONp(interface{}, ...interface{}) error
+	OutJSONt(interface{}, string, Lookuper) error
+	OutLines(string) []string
+	OutLinesf(string, ...interface{}) []string
+	OutLinesp(...interface{}) []string
+	OutLinest(string, Lookuper) []string
 	OutStatus(string) (string, error)
 	OutStatusf(string, ...interface{}) (string, error)
 	OutStatusp(...interface{}) (string, error)
@@ -26,14 +94,31 @@ type Interface interface {
 	Outf(string, ...interface{}) string
 	Outp(...interface{}) string
 	Outt(string, Lookuper) string
+	Parallel(int, ...string) error
+	Pipe(...interface{}) *Pipeline
 	Run(string) error
 	Runf(string, ...interface{}) error
 	Runp(...interface{}) error
 	Runt(string, Lookuper) error
+	ScriptPrint(...interface{}) string
+	ScriptPrintf(string, ...interface{}) string
+	ScriptTemplate(string, Lookuper) string
+	Start(string) *Job
+	Startf(string, ...interface{}) *Job
+	Startp(...interface{}) *Job
+	Startt(string, Lookuper) *Job
+	Stream(string) (<-chan Line, *Result)
+	Streamf(string, ...interface{}) (<-chan Line, *Result)
+	Streamp(...interface{}) (<-chan Line, *Result)
+	Streamt(string, Lookuper) (<-chan Line, *Result)
 	Succeeds(string) bool
 	Succeedsf(string, ...interface{}) bool
 	Succeedsp(...interface{}) bool
 	Succeedst(string, Lookuper) bool
+	WaitJobs() error
+	With(...Option) Interface
+	WithDir(string) Interface
+	WithEnv(Environer) Interface
 }
 
 // ensure compatible w/ Shell
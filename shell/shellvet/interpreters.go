@@ -0,0 +1,183 @@
+package shellvet
+
+// Finding the Interpreter of the shell a method is called on, so that
+// scripts for other languages aren't checked as Bash.
+
+import (
+	"go/ast"
+	"go/types"
+	"strconv"
+
+	"github.com/justjake/go-scripting/shell"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// builtins are the Interpreters of the shell package, by name.
+var builtins = map[string]*shell.Interpreter{
+	"Bash":    shell.Bash,
+	"Sh":      shell.Sh,
+	"Zsh":     shell.Zsh,
+	"Python3": shell.Python3,
+}
+
+// assignments maps each variable that is assigned exactly once to the
+// expression it is assigned. Variables assigned more than once map to nil.
+func assignments(pass *analysis.Pass, inspect *inspector.Inspector) map[*types.Var]ast.Expr {
+	vars := make(map[*types.Var]ast.Expr)
+	assign := func(id *ast.Ident, value ast.Expr) {
+		v, ok := pass.TypesInfo.ObjectOf(id).(*types.Var)
+		if !ok {
+			return
+		}
+		if _, seen := vars[v]; seen {
+			value = nil
+		}
+		vars[v] = value
+	}
+	inspect.Preorder([]ast.Node{(*ast.AssignStmt)(nil), (*ast.ValueSpec)(nil)}, func(n ast.Node) {
+		var lhs []ast.Expr
+		var rhs []ast.Expr
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			lhs, rhs = n.Lhs, n.Rhs
+		case *ast.ValueSpec:
+			for _, name := range n.Names {
+				lhs = append(lhs, name)
+			}
+			rhs = n.Values
+		}
+		for i, l := range lhs {
+			id, ok := l.(*ast.Ident)
+			if !ok {
+				continue
+			}
+			var value ast.Expr
+			if len(lhs) == len(rhs) {
+				value = rhs[i]
+			}
+			assign(id, value)
+		}
+	})
+	return vars
+}
+
+// interpreterOf returns the Interpreter of the shell expr evaluates to, as far
+// as can be told from the code, or nil if it isn't known. It follows shells
+// derived with With and the like, Shell literals, and variables assigned once.
+func interpreterOf(pass *analysis.Pass, vars map[*types.Var]ast.Expr, expr ast.Expr) *shell.Interpreter {
+	for depth := 0; expr != nil && depth < 100; depth++ {
+		switch e := ast.Unparen(expr).(type) {
+		case *ast.Ident:
+			v, ok := pass.TypesInfo.Uses[e].(*types.Var)
+			if !ok {
+				return nil
+			}
+			expr = vars[v]
+		case *ast.UnaryExpr:
+			expr = e.X
+		case *ast.CompositeLit:
+			if !isShellType(pass.TypesInfo.TypeOf(e), "Shell") {
+				return nil
+			}
+			for _, elt := range e.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok && isIdent(kv.Key, "Interpreter") {
+					return interpreterValue(pass, vars, kv.Value)
+				}
+			}
+			return nil
+		case *ast.CallExpr:
+			sel, ok := e.Fun.(*ast.SelectorExpr)
+			if !ok || !isShellMethod(pass, sel) {
+				return nil
+			}
+			if sel.Sel.Name == "With" {
+				for i := len(e.Args) - 1; i >= 0; i-- {
+					if interp := interpOption(pass, vars, e.Args[i]); interp != nil {
+						return interp
+					}
+				}
+			}
+			expr = sel.X
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// isShellMethod returns true if sel is a method of the shell package that
+// returns a shell derived from its receiver.
+func isShellMethod(pass *analysis.Pass, sel *ast.SelectorExpr) bool {
+	fn, ok := pass.TypesInfo.Uses[sel.Sel].(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != shellPath {
+		return false
+	}
+	switch fn.Name() {
+	case "With", "Must", "Copy", "WithDir", "WithEnv", "Feed", "FeedString", "FeedJSON":
+		return true
+	}
+	return false
+}
+
+// interpOption returns the Interpreter of an Interp(...) option, or nil.
+func interpOption(pass *analysis.Pass, vars map[*types.Var]ast.Expr, expr ast.Expr) *shell.Interpreter {
+	call, ok := ast.Unparen(expr).(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return nil
+	}
+	fn, ok := calleeFunc(pass, call.Fun)
+	if !ok || fn.Pkg().Path() != shellPath || fn.Name() != "Interp" {
+		return nil
+	}
+	return interpreterValue(pass, vars, call.Args[0])
+}
+
+// interpreterValue returns a stand-in for the Interpreter expr evaluates to:
+// one of the built-in Interpreters, or an Interpreter literal. Literals with
+// a Quote function stand for languages other than shells.
+func interpreterValue(pass *analysis.Pass, vars map[*types.Var]ast.Expr, expr ast.Expr) *shell.Interpreter {
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.Ident:
+		if v, ok := pass.TypesInfo.Uses[e].(*types.Var); ok && vars[v] != nil && vars[v] != expr {
+			return interpreterValue(pass, vars, vars[v])
+		}
+	case *ast.SelectorExpr:
+		if v, ok := pass.TypesInfo.Uses[e.Sel].(*types.Var); ok && v.Pkg() != nil && v.Pkg().Path() == shellPath {
+			return builtins[v.Name()]
+		}
+	case *ast.UnaryExpr:
+		lit, ok := e.X.(*ast.CompositeLit)
+		if !ok {
+			return nil
+		}
+		interp := &shell.Interpreter{Args: []string{"bash", "-c"}}
+		for _, elt := range lit.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok && isIdent(kv.Key, "Quote") {
+				interp.Quote = strconv.Quote
+			}
+		}
+		return interp
+	}
+	return nil
+}
+
+// calleeFunc returns the package-level function fun refers to.
+func calleeFunc(pass *analysis.Pass, fun ast.Expr) (*types.Func, bool) {
+	var id *ast.Ident
+	switch f := ast.Unparen(fun).(type) {
+	case *ast.Ident:
+		id = f
+	case *ast.SelectorExpr:
+		id = f.Sel
+	default:
+		return nil, false
+	}
+	fn, ok := pass.TypesInfo.Uses[id].(*types.Func)
+	return fn, ok && fn.Pkg() != nil
+}
+
+func isIdent(expr ast.Expr, name string) bool {
+	id, ok := expr.(*ast.Ident)
+	return ok && id.Name == name
+}
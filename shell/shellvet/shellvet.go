@@ -0,0 +1,325 @@
+// Package shellvet is a go vet style analyzer that checks the scripts passed
+// to the shell package, so that syntax errors are found when the program is
+// built instead of when the script runs.
+//
+// It checks constant strings passed as the script of a Shell method, like
+// Run, and the scriptformat of a method like Runf or ScriptPrintf. Scripts
+// are parsed with Shell.Check, in the language of the shell's Interpreter, if
+// it can be told from the code, like for
+//
+//   py := sh.With(shell.Interp(shell.Python3))
+//   py.Run(`print(1)`) // not a shell script, so not checked
+//
+// Otherwise, scripts are parsed as Bash. Formats are also checked for verbs
+// that don't work with escaped values:
+//
+//   sh.Runf(`echo %q`, name)           // %q quotes for Go, not the shell
+//   sh.Runf(`sleep %d`, sh.Escape(n))  // %d formats a Raw string
+//   sh.Runf(`echo '%s'`, sh.Escape(s)) // the value is quoted twice
+//
+// Quotes around a verb with an unescaped value, like `echo '%s'`, are fine:
+// the value is escaped for the inside of the quotes.
+//
+// To run it with go vet:
+//
+//   go build -o /tmp/shellvet bin/shellvet.go
+//   go vet -vettool=/tmp/shellvet ./...
+package shellvet
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/justjake/go-scripting/shell"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const shellPath = "github.com/justjake/go-scripting/shell"
+
+// Analyzer checks scripts and script formats passed to the shell package.
+var Analyzer = &analysis.Analyzer{
+	Name:     "shellvet",
+	Doc:      "check shell scripts and script formats passed to the shell package",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	vars := assignments(pass, inspect)
+	inspect.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+		fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+		if !ok || fn.Pkg() == nil || fn.Pkg().Path() != shellPath {
+			return
+		}
+		// Exec methods and Argv functions build an argv, not a script.
+		if strings.HasPrefix(fn.Name(), "Exec") || strings.HasPrefix(fn.Name(), "Argv") {
+			return
+		}
+		sh := &shell.Shell{}
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok && signatureOf(fn).Recv() != nil {
+			sh.Interpreter = interpreterOf(pass, vars, sel.X)
+		}
+		if sh.Interpreter != nil && sh.Interpreter.Quote != nil {
+			// Scripts and formats for other languages aren't checked.
+			return
+		}
+		sig := signatureOf(fn)
+		for i := 0; i < sig.Params().Len() && i < len(call.Args); i++ {
+			switch sig.Params().At(i).Name() {
+			case "script":
+				checkScript(pass, sh, call.Args[i])
+			case "scripts":
+				for _, arg := range call.Args[i:] {
+					checkScript(pass, sh, arg)
+				}
+			case "scriptformat":
+				checkFormat(pass, fn, call.Args[i], call.Args[i+1:], call.Ellipsis.IsValid())
+			}
+		}
+	})
+	return nil, nil
+}
+
+// signatureOf returns the signature of fn. Methods of shell.Interface and
+// MockShell have the parameter names of the Shell method.
+func signatureOf(fn *types.Func) *types.Signature {
+	sig := fn.Type().(*types.Signature)
+	if sig.Recv() == nil {
+		return sig
+	}
+	shellType := fn.Pkg().Scope().Lookup("Shell")
+	if shellType == nil {
+		return sig
+	}
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(shellType.Type()), false, fn.Pkg(), fn.Name())
+	if method, ok := obj.(*types.Func); ok {
+		return method.Type().(*types.Signature)
+	}
+	return sig
+}
+
+// constString returns the value of expr if it is a constant string.
+func constString(pass *analysis.Pass, expr ast.Expr) (string, bool) {
+	tv, ok := pass.TypesInfo.Types[expr]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", false
+	}
+	return constant.StringVal(tv.Value), true
+}
+
+// posAt returns the position of the byte at offset in the value of expr, if
+// expr is a raw string literal, or else the position of expr.
+func posAt(expr ast.Expr, offset int) token.Pos {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || !strings.HasPrefix(lit.Value, "`") || strings.Contains(lit.Value, "\r") {
+		return expr.Pos()
+	}
+	return lit.Pos() + 1 + token.Pos(offset)
+}
+
+func checkScript(pass *analysis.Pass, sh *shell.Shell, expr ast.Expr) {
+	script, ok := constString(pass, expr)
+	if !ok {
+		return
+	}
+	err := sh.Check(script)
+	var syntaxErr *shell.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		pos := posAt(expr, offsetOf(script, syntaxErr.Line, syntaxErr.Col))
+		if pos == expr.Pos() {
+			pass.Reportf(pos, "%v", err)
+		} else {
+			pass.Reportf(pos, "script: %v", syntaxErr.Err)
+		}
+	} else if err != nil {
+		pass.Reportf(expr.Pos(), "%v", err)
+	}
+}
+
+// offsetOf returns the offset of a line and column in s.
+func offsetOf(s string, line, col int) int {
+	offset := 0
+	for ; line > 1; line-- {
+		i := strings.IndexByte(s[offset:], '\n')
+		if i < 0 {
+			break
+		}
+		offset += i + 1
+	}
+	if offset+col-1 > len(s) {
+		return len(s)
+	}
+	return offset + col - 1
+}
+
+func checkFormat(pass *analysis.Pass, fn *types.Func, expr ast.Expr, args []ast.Expr, spread bool) {
+	format, ok := constString(pass, expr)
+	if !ok {
+		return
+	}
+	verbs, err := parseVerbs(format)
+	if err != nil {
+		pass.Reportf(expr.Pos(), "%s format %v", fn.Name(), err)
+		return
+	}
+	if spread {
+		// The values are in a slice; only the script can be checked.
+		args = nil
+	}
+
+	ok = true
+	used := 0
+	for _, v := range verbs {
+		if v.arg >= used {
+			used = v.arg + 1
+		}
+		if v.arg < 0 || args == nil {
+			continue
+		}
+		if v.arg >= len(args) {
+			pass.Reportf(posAt(expr, v.start), "%s format %s reads arg #%d, but call has %d args", fn.Name(), v.spec, v.arg+1, len(args))
+			ok = false
+			continue
+		}
+		if v.verb == '*' {
+			continue
+		}
+		if msg := verbProblem(pass, fn.Pkg(), format, v, args[v.arg]); msg != "" {
+			pass.Reportf(posAt(expr, v.start), "%s format %s %s", fn.Name(), v.spec, msg)
+			ok = false
+		}
+	}
+	if args != nil && used < len(args) {
+		pass.Reportf(args[used].Pos(), "%s call needs %d args but has %d args", fn.Name(), used, len(args))
+		ok = false
+	}
+	if !ok {
+		return
+	}
+
+	var vs []interface{}
+	if args == nil {
+		// Without the values' types, assume they are Raw, which can go
+		// anywhere in a script.
+		for i := 0; i < used; i++ {
+			vs = append(vs, shell.Raw("x"))
+		}
+	} else {
+		for _, arg := range args {
+			vs = append(vs, standIn(fn.Pkg(), pass.TypesInfo.TypeOf(arg)))
+		}
+	}
+	if err := shell.CheckFormat(format, vs...); err != nil {
+		pass.Reportf(expr.Pos(), "%s format: %v", fn.Name(), err)
+	}
+}
+
+// verbProblem describes what is wrong with formatting arg with v, or returns
+// "" if nothing is.
+func verbProblem(pass *analysis.Pass, shellPkg *types.Package, format string, v fmtVerb, arg ast.Expr) string {
+	typ := pass.TypesInfo.TypeOf(arg)
+	if typ == nil {
+		return ""
+	}
+	escaped := isShellType(typ, "Raw") || implements(shellPkg, typ, "Escaper")
+	switch {
+	case v.verb == 'q':
+		return "quotes the value for Go, not the shell: use %s"
+	case strings.ContainsRune("bcdoOxXUeEfFgG", v.verb) && (escaped || isString(typ)):
+		return fmt.Sprintf("formats a value of type %s: use %%s", typeName(typ))
+	case escaped && isWrapped(format, v):
+		return fmt.Sprintf("wraps a value of type %s, which is already escaped, in more quotes", typeName(typ))
+	}
+	return ""
+}
+
+// isWrapped returns true if v is the only thing inside a pair of quotes, like
+// '%s'.
+func isWrapped(format string, v fmtVerb) bool {
+	if v.start == 0 || v.end >= len(format) {
+		return false
+	}
+	before, after := format[v.start-1], format[v.end]
+	return before == after && (before == '\'' || before == '"')
+}
+
+// isString returns true for strings, including Raw and Secret, and []byte.
+func isString(typ types.Type) bool {
+	switch t := typ.Underlying().(type) {
+	case *types.Basic:
+		return t.Info()&types.IsString != 0
+	case *types.Slice:
+		basic, ok := t.Elem().Underlying().(*types.Basic)
+		return ok && basic.Kind() == types.Byte
+	}
+	return false
+}
+
+func isShellType(typ types.Type, name string) bool {
+	named, ok := typ.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == shellPath && obj.Name() == name
+}
+
+// implements returns true if typ implements the interface with name in the
+// shell package.
+func implements(shellPkg *types.Package, typ types.Type, name string) bool {
+	obj := shellPkg.Scope().Lookup(name)
+	if obj == nil {
+		return false
+	}
+	iface, ok := obj.Type().Underlying().(*types.Interface)
+	if !ok {
+		return false
+	}
+	if _, isIface := typ.Underlying().(*types.Interface); isIface {
+		return types.Identical(typ.Underlying(), iface)
+	}
+	return types.Implements(typ, iface) || types.Implements(types.NewPointer(typ), iface)
+}
+
+func typeName(typ types.Type) string {
+	return types.TypeString(typ, func(p *types.Package) string { return p.Name() })
+}
+
+// standIn returns a value with a type like typ, for shell.CheckFormat.
+func standIn(shellPkg *types.Package, typ types.Type) interface{} {
+	switch {
+	case typ == nil, isShellType(typ, "Raw"), implements(shellPkg, typ, "Escaper"):
+		return shell.Raw("x")
+	case implements(shellPkg, typ, "Arger"):
+		return []string{"x"}
+	case isString(typ):
+		return "x"
+	}
+	switch t := typ.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case t.Info()&types.IsInteger != 0:
+			return 0
+		case t.Info()&types.IsFloat != 0:
+			return 0.0
+		case t.Info()&types.IsBoolean != 0:
+			return true
+		}
+	case *types.Slice, *types.Array, *types.Map:
+		return []string{"x"}
+	case *types.Interface:
+		// Could be anything, including a Raw value.
+		return shell.Raw("x")
+	}
+	return "x"
+}
@@ -0,0 +1,61 @@
+package example
+
+import "github.com/justjake/go-scripting/shell"
+
+type path string
+
+func (p path) Escape() shell.Raw { return shell.Raw(p) }
+
+func scripts(sh *shell.Shell, iface shell.Interface, name string, n int, files []string) {
+	sh.Run(`echo ok`)
+	sh.Run(`if true; then echo ok; fi`)
+	sh.Run(`echo 'unclosed`)             // want `script: reached EOF without closing quote '`
+	sh.Run("if true; then\n  echo ok\n") // want `script:1:1: if statement must end with "fi"`
+	iface.Run(`echo $((1 +))`)           // want `script: \+ must be followed by an expression`
+	sh.Parallel(2, `echo ok`, `done`)    // want `script: "done" can only be used to end a loop`
+
+	const script = `echo ok; fi`
+	sh.Run(script) // want `script:1:10: "fi" can only be used to end an if`
+	sh.Run(name)
+}
+
+func interpreters(sh *shell.Shell, quote func(string) string) {
+	py := sh.With(shell.Interp(shell.Python3))
+	py.Run(`print(1)`)
+	py.Runf(`print(%q)`, "x")
+	sh.With(shell.Interp(shell.Python3)).Must().Run(`print(1)`)
+	(&shell.Shell{Interpreter: shell.Python3}).Run(`print(1)`)
+	node := &shell.Interpreter{Args: []string{"node", "-e"}, Quote: quote}
+	sh.With(shell.Interp(node)).Run(`console.log(1)`)
+
+	sh.With(shell.Interp(shell.Bash)).Run(`print(1)`) // want `script: "foo\(" must be followed by \)`
+	sh.With(shell.Interp(shell.Sh)).Run(`a=(1 2)`)    // want `script: arrays are a bash/mksh feature`
+	py.With(shell.Interp(shell.Bash)).Run(`fi`)       // want `script: "fi" can only be used to end an if`
+}
+
+func formats(sh *shell.Shell, name string, n int, files []string, vs []interface{}) {
+	sh.Runf(`echo %s; echo $((%d + 1))`, name, n)
+	sh.Runf(`echo '%s' "%s"`, name, name)
+	sh.Runf(`echo %s`, files)
+	sh.Runf("echo `%s`", sh.Escape(name))
+	sh.Runf(`echo %s %%`, name)
+	sh.Runf(`echo %[2]s %[1]s`, name, name)
+	sh.Runf(`printf %*d`, n, n)
+	sh.Runf(`echo %s`, vs...)
+	sh.OutInf("input", `cat; echo %s`, name)
+	sh.ExecCmdf(`echo %q`, name)
+
+	sh.Runf(`echo %q`, name)              // want `Runf format %q quotes the value for Go, not the shell: use %s`
+	sh.Runf(`sleep %d`, sh.Escape(n))     // want `Runf format %d formats a value of type shell.Raw: use %s`
+	sh.Runf(`sleep %d`, name)             // want `Runf format %d formats a value of type string: use %s`
+	sh.Runf(`echo '%s'`, sh.Escape(name)) // want `Runf format %s wraps a value of type shell.Raw, which is already escaped, in more quotes`
+	sh.Runf(`ls "%s"`, path(name))        // want `Runf format %s wraps a value of type example.path, which is already escaped, in more quotes`
+	sh.Runf(`echo %s %s`, name)           // want `Runf format %s reads arg #2, but call has 1 args`
+	sh.Runf(`echo %s`, name, name)        // want `Runf call needs 1 args but has 2 args`
+	sh.Runf(`echo %`, name)               // want `Runf format % is missing a verb`
+
+	sh.Runf("echo `%s`", name)             // want `Runf format: can't safely escape a value inside backticks`
+	sh.Runf(`echo $((%s + 1))`, name)      // want `Runf format: can't safely escape a value in arithmetic, unless it is an integer`
+	sh.Runf(`echo %s; fi`, n)              // want `Runf format: script:1:9: "fi" can only be used to end an if`
+	shell.ScriptPrintf(`echo %s |`, vs...) // want `ScriptPrintf format: script:1:8: \| must be followed by a statement`
+}
@@ -0,0 +1,52 @@
+// Package shell is a stand-in for the shell package, with the declarations
+// shellvet looks at.
+package shell
+
+type Shell struct {
+	Interpreter *Interpreter
+}
+
+func (sh *Shell) Run(script string) error                                            { return nil }
+func (sh *Shell) Runf(scriptformat string, vs ...interface{}) error                  { return nil }
+func (sh *Shell) OutInf(input string, scriptformat string, vs ...interface{}) string { return "" }
+func (sh *Shell) ExecCmdf(scriptformat string, vs ...interface{}) error              { return nil }
+func (sh *Shell) Parallel(n int, scripts ...string) error                            { return nil }
+func (sh *Shell) Escape(val interface{}) Raw                                         { return "" }
+func (sh *Shell) With(opts ...Option) Interface                                      { return sh }
+func (sh *Shell) Must() Interface                                                    { return sh }
+
+type Option func(*Shell)
+
+type Interpreter struct {
+	Args  []string
+	Quote func(s string) string
+}
+
+var (
+	Bash    = &Interpreter{}
+	Sh      = &Interpreter{}
+	Python3 = &Interpreter{}
+)
+
+func Interp(interp *Interpreter) Option { return nil }
+
+type Interface interface {
+	Run(string) error
+	Runf(string, ...interface{}) error
+	With(...Option) Interface
+	Must() Interface
+}
+
+func ScriptPrintf(scriptformat string, vs ...interface{}) string { return "" }
+
+type Raw string
+
+type Secret string
+
+type Escaper interface {
+	Escape() Raw
+}
+
+type Arger interface {
+	Args() []string
+}
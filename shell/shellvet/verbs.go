@@ -0,0 +1,93 @@
+package shellvet
+
+import (
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// fmtVerb is a verb in a format string, like %-10s.
+type fmtVerb struct {
+	// Offsets of the verb in the format.
+	start, end int
+	spec       string
+	verb       rune
+	// Index of the value the verb formats, or -1 for %%. A * width or
+	// precision is a verb of its own, with verb '*'.
+	arg int
+}
+
+// parseVerbs returns the verbs in format, in the order fmt reads them.
+func parseVerbs(format string) ([]fmtVerb, error) {
+	var verbs []fmtVerb
+	arg := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		start := i
+		i++
+		for i < len(format) && isFlag(format[i]) {
+			i++
+		}
+		// Width, then precision.
+		for part := 0; part < 2; part++ {
+			if part == 1 {
+				if i >= len(format) || format[i] != '.' {
+					break
+				}
+				i++
+			}
+			if n, end, ok := argIndex(format, i); ok {
+				arg, i = n, end
+			}
+			if i < len(format) && format[i] == '*' {
+				verbs = append(verbs, fmtVerb{start: start, end: i + 1, spec: format[start : i+1], verb: '*', arg: arg})
+				arg++
+				i++
+				continue
+			}
+			for i < len(format) && '0' <= format[i] && format[i] <= '9' {
+				i++
+			}
+		}
+		if n, end, ok := argIndex(format, i); ok {
+			arg, i = n, end
+		}
+		if i >= len(format) {
+			return nil, fmt.Errorf("%s is missing a verb", format[start:])
+		}
+
+		verb, size := utf8.DecodeRuneInString(format[i:])
+		v := fmtVerb{start: start, end: i + size, spec: format[start : i+size], verb: verb, arg: -1}
+		if verb != '%' {
+			v.arg = arg
+			arg++
+		}
+		verbs = append(verbs, v)
+		i += size - 1
+	}
+	return verbs, nil
+}
+
+func isFlag(c byte) bool {
+	return c == '+' || c == '-' || c == '#' || c == ' ' || c == '0'
+}
+
+// argIndex parses an explicit argument index like [2] at i, and returns the
+// index of the value it refers to.
+func argIndex(format string, i int) (int, int, bool) {
+	if i >= len(format) || format[i] != '[' {
+		return 0, i, false
+	}
+	for end := i + 1; end < len(format); end++ {
+		if format[end] == ']' {
+			n, err := strconv.Atoi(format[i+1 : end])
+			if err != nil || n < 1 {
+				return 0, i, false
+			}
+			return n - 1, end + 1, true
+		}
+	}
+	return 0, i, false
+}
@@ -0,0 +1,41 @@
+package shellvet
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseVerbs(t *testing.T) {
+	cases := []struct {
+		format string
+		specs  []string
+		args   []int
+	}{
+		{`echo %s`, []string{"%s"}, []int{0}},
+		{`echo 100%% %-10s %v`, []string{"%%", "%-10s", "%v"}, []int{-1, 0, 1}},
+		{`printf %*.*f %d`, []string{"%*", "%*.*", "%*.*f", "%d"}, []int{0, 1, 2, 3}},
+		{`echo %[2]s %[1]s %s`, []string{"%[2]s", "%[1]s", "%s"}, []int{1, 0, 1}},
+		{`echo %6.2f`, []string{"%6.2f"}, []int{0}},
+	}
+
+	for _, c := range cases {
+		verbs, err := parseVerbs(c.format)
+		if err != nil {
+			t.Errorf("parseVerbs(%q) -> %v", c.format, err)
+			continue
+		}
+		var specs []string
+		var args []int
+		for _, v := range verbs {
+			specs = append(specs, v.spec)
+			args = append(args, v.arg)
+		}
+		if !reflect.DeepEqual(specs, c.specs) || !reflect.DeepEqual(args, c.args) {
+			t.Errorf("parseVerbs(%q) -> %q %v != %q %v", c.format, specs, args, c.specs, c.args)
+		}
+	}
+
+	if _, err := parseVerbs(`echo 100%`); err == nil {
+		t.Errorf("parseVerbs(`echo 100%%`) should fail")
+	}
+}
@@ -0,0 +1,77 @@
+package shell
+
+// Ways to feed input to a script's Stdin.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Feed returns a copy of the shell that connects r to the Stdin of scripts,
+// like a heredoc. A reader can only be read once, so feed a new reader for
+// each script, unless r is meant to be shared.
+//
+//   sh.Feed(file).Run(`psql mydb`)
+func (sh *Shell) Feed(r io.Reader) Interface {
+	copied := sh.with()
+	copied.Stdin = r
+	return copied
+}
+
+// FeedString returns a copy of the shell that writes input to the Stdin of
+// scripts. Unlike Feed, every script receives all of input.
+//
+//   sh.FeedString(manifest).Run(`kubectl apply -f -`)
+func (sh *Shell) FeedString(input string) Interface {
+	return sh.Feed(newFedInput([]byte(input)))
+}
+
+// FeedJSON returns a copy of the shell that writes v, encoded as JSON, to the
+// Stdin of scripts. Like FeedString, every script receives all of it. FeedJSON
+// panics if v can't be encoded.
+//
+//   sh.FeedJSON(patch).Run(`kubectl patch deploy/api --type merge -p "$(cat)"`)
+func (sh *Shell) FeedJSON(v interface{}) Interface {
+	return sh.Feed(jsonReader(v))
+}
+
+// OutIn writes input to the Stdin of the script, and returns its Stdout like
+// Out.
+//
+//   sorted := sh.OutIn(`sort -u`, names)
+//
+// @StaticCompose.Inside("formatters")
+func (sh *Shell) OutIn(script string, input string) string {
+	return sh.FeedString(input).Out(script)
+}
+
+// fedInput is a Stdin that each script reads from the start. Reading it
+// directly reads it once, like any other reader.
+type fedInput struct {
+	*bytes.Reader
+	data []byte
+}
+
+func newFedInput(data []byte) *fedInput {
+	return &fedInput{Reader: bytes.NewReader(data), data: data}
+}
+
+// stdin returns the reader to connect to the Stdin of a script: a fresh
+// reader for input fed by FeedString or FeedJSON, or else the shell's Stdin.
+func (sh *Shell) stdin() io.Reader {
+	if fed, ok := sh.Stdin.(*fedInput); ok {
+		return bytes.NewReader(fed.data)
+	}
+	return sh.Stdin
+}
+
+// jsonReader encodes v as JSON.
+func jsonReader(v interface{}) io.Reader {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Errorf("Could not encode %T as JSON for Stdin: %v", v, err))
+	}
+	return newFedInput(data)
+}
@@ -0,0 +1,80 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFeed(t *testing.T) {
+	sh := &Shell{}
+	cases := []struct {
+		sh       Interface
+		script   string
+		expected string
+	}{
+		{sh.Feed(strings.NewReader("b\na\n")), `sort`, "a\nb"},
+		{sh.FeedString("hello"), `tr a-z A-Z`, "HELLO"},
+		{sh.FeedJSON(map[string]int{"n": 1}), `cat`, `{"n":1}`},
+		{&Shell{Stdin: strings.NewReader("x")}, `wc -c`, "1"},
+	}
+	for _, c := range cases {
+		if out := strings.TrimSpace(c.sh.Out(c.script)); out != c.expected {
+			t.Errorf("Out(%q) with stdin -> %q != %q", c.script, out, c.expected)
+		}
+	}
+
+	if out := sh.OutInf("c\nb\na", `sort %s`, "-r"); out != "c\nb\na" {
+		t.Errorf("OutInf(...) -> %q", out)
+	}
+	if sh.Stdin != nil {
+		t.Errorf("Feed should not modify the shell")
+	}
+	if out := (&Shell{Stdin: strings.NewReader("in")}).Pipe(`cat`, `tr a-z A-Z`).Out(); out != "IN" {
+		t.Errorf("Pipe(...).Out() with Stdin -> %q", out)
+	}
+}
+
+func TestFeedStringEveryScript(t *testing.T) {
+	sh := (&Shell{}).FeedString("hello")
+	for i := 0; i < 2; i++ {
+		if out := sh.Out(`cat`); out != "hello" {
+			t.Errorf("Out(`cat`) #%d -> %q", i+1, out)
+		}
+	}
+	if out := sh.Pipe(`cat`, `tr a-z A-Z`).Out(); out != "HELLO" {
+		t.Errorf("Pipe(...).Out() after other scripts -> %q", out)
+	}
+	json := (&Shell{}).FeedJSON([]int{1})
+	if a, b := json.Out(`cat`), json.Out(`cat`); a != "[1]" || b != "[1]" {
+		t.Errorf("FeedJSON(...).Out(`cat`) twice -> %q, %q", a, b)
+	}
+}
+
+func TestFeedRetries(t *testing.T) {
+	sh := (&Shell{}).With(Retries(Retry{Attempts: 2}))
+	res := sh.FeedString("data").Do(`test "$(cat)" = data && exit 1`)
+	if res.Attempts != 2 {
+		t.Errorf("Do(...) -> %d attempts, expected 2", res.Attempts)
+	}
+}
+
+func TestMockShellStdin(t *testing.T) {
+	sh := &MockShell{}
+	sh.AddMock(MockCall{Script: "psql", Stdout: "ok"})
+	sh.Expect(MatchExact("kubectl apply -f -"), MockCall{}).WithStdin("kind: Pod")
+
+	if out := sh.FeedString("select 1").Out("psql"); out != "ok" {
+		t.Errorf("Out(`psql`) -> %q", out)
+	}
+	if stdins := sh.Stdins("psql"); len(stdins) != 1 || stdins[0] != "select 1" {
+		t.Errorf("Stdins(`psql`) -> %q", stdins)
+	}
+	sh.FeedString("kind: Pod").Run("kubectl apply -f -")
+
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(error).Error(), "Unexpected stdin") {
+			t.Errorf("Expected a panic for unexpected stdin, got %v", r)
+		}
+	}()
+	sh.FeedString("kind: Job").Run("kubectl apply -f -")
+}
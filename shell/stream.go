@@ -0,0 +1,259 @@
+package shell
+
+// Ways to run a script that process its output while it is still running.
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Line is a line of output from a streaming script.
+type Line struct {
+	// Text of the line, without the trailing newline.
+	Text string
+	// File descriptor the line was written to: 1 for Stdout, 2 for Stderr.
+	Fd int
+	// When the line was read.
+	Time time.Time
+}
+
+// IsStderr returns true if the line was written to Stderr.
+func (l Line) IsStderr() bool {
+	return l.Fd == 2
+}
+
+// Lines runs the script and calls fn with each line of its Stdout as soon as
+// it is written. The script's Stderr is connected to the shell's Stderr.
+//
+// If fn returns an error, the script is killed, and Lines returns the error
+// from fn. Otherwise, Lines returns the script's exit status like Run.
+//
+//   sh.Lines(`kubectl logs -f deploy/api`, func(line string) error {
+//     if strings.Contains(line, "ready") {
+//       return io.EOF
+//     }
+//     return nil
+//   })
+//
+// @StaticCompose.Inside("formatters")
+func (sh *Shell) Lines(script string, fn func(line string) error) error {
+	stderr := sh.Stderr
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+	res, stopped := sh.stream(sh.Cmd(script), script, stderr, func(line Line) error {
+		return fn(line.Text)
+	})
+	if !stopped {
+		sh.onError(res.err)
+	}
+	return res.Err()
+}
+
+// Stream runs the script in the background, and sends each line of its Stdout
+// and Stderr to the returned channel as soon as it is written. The channel is
+// closed once the script exits, after which the returned Result describes the
+// script. Don't read the Result before the channel is closed.
+//
+// You must receive from the channel until it is closed, or cancel the shell's
+// context, which kills the script.
+//
+// Because Stream returns before the script exits, the shell's ErrorPolicy is
+// applied in the background. If the policy panics, as in Must mode, the panic
+// is raised by res.Err, in the goroutine that reads the Result:
+//
+//   lines, res := sh.Must().Stream(`make`)
+//   for line := range lines {
+//     log.Println(line.Fd, line.Text)
+//   }
+//   res.Err() // panics if make failed
+//
+// @StaticCompose.Inside("formatters")
+func (sh *Shell) Stream(script string) (<-chan Line, *Result) {
+	cmd := sh.Cmd(script)
+	return sh.streamChan(func(fn func(Line) error) *Result {
+		res, stopped := sh.stream(cmd, script, nil, fn)
+		if !stopped {
+			sh.onErrorLater(res)
+		}
+		return res
+	})
+}
+
+// onErrorLater is onError for a Result read by another goroutine. If the
+// ErrorPolicy panics, the panic is recovered, and raised again by res.Err.
+func (sh *Shell) onErrorLater(res *Result) {
+	defer func() {
+		if p := recover(); p != nil {
+			res.panic = p
+		}
+	}()
+	sh.onError(res.err)
+}
+
+// streamChan runs stream in the background, and connects its lines to a
+// channel.
+func (sh *Shell) streamChan(stream func(fn func(Line) error) *Result) (<-chan Line, *Result) {
+	lines := make(chan Line)
+	res := new(Result)
+	var done <-chan struct{}
+	if sh.ctx != nil {
+		done = sh.ctx.Done()
+	}
+
+	go func() {
+		defer close(lines)
+		*res = *stream(func(line Line) error {
+			select {
+			case lines <- line:
+				return nil
+			case <-done:
+				return sh.ctx.Err()
+			}
+		})
+	}()
+
+	return lines, res
+}
+
+// stream runs cmd, and calls fn for each line of its output. If stderr is nil,
+// lines from Stderr are passed to fn as well; otherwise Stderr is connected to
+// the given writer. The lines are not captured in the Result.
+//
+// If fn returns an error, the script is killed, stream returns the error in
+// the Result, and stopped is true. Otherwise, the caller should apply the
+// shell's ErrorPolicy to the Result.
+func (sh *Shell) stream(cmd *exec.Cmd, script string, stderr io.Writer, fn func(Line) error) (res *Result, stopped bool) {
+	lines := make(chan Line)
+	var readers sync.WaitGroup
+	tail := &tailWriter{}
+	// The lines are kept for Tracers that record output, like a Cassette.
+	var output [3]strings.Builder
+	keepOutput := sh.Trace != nil && tracesOutput(sh.Trace)
+	read := func(r io.Reader, fd int) {
+		defer readers.Done()
+		buf := bufio.NewReader(r)
+		for {
+			text, err := buf.ReadString('\n')
+			if text != "" {
+				if fd == 2 {
+					io.WriteString(tail, text)
+				}
+				if keepOutput {
+					output[fd].WriteString(text)
+				}
+				lines <- Line{strings.TrimSuffix(text, "\n"), fd, time.Now()}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	// The script writes to pipes directly, so that its exit isn't held up by
+	// processes it started that still have them open. Once the script is
+	// killed, the pipes are closed to stop reading from those processes.
+	var pipes []*os.File
+	pipe := func() (r, w *os.File) {
+		r, w, err := os.Pipe()
+		if err != nil {
+			panic(fmt.Errorf("Could not create a pipe for script %s: %v", script, err))
+		}
+		pipes = append(pipes, r)
+		return r, w
+	}
+	readLines := func(fd int) *os.File {
+		r, w := pipe()
+		readers.Add(1)
+		go read(r, fd)
+		return w
+	}
+	outW := readLines(1)
+	cmd.Stdout = outW
+	var errW *os.File
+	var copying sync.WaitGroup
+	switch w := stderr.(type) {
+	case nil:
+		errW = readLines(2)
+		cmd.Stderr = errW
+	case *os.File:
+		cmd.Stderr = w
+	default:
+		var r *os.File
+		r, errW = pipe()
+		copying.Add(1)
+		go func() {
+			defer copying.Done()
+			io.Copy(io.MultiWriter(w, tail), r)
+		}()
+		cmd.Stderr = errW
+	}
+
+	go func() {
+		readers.Wait()
+		close(lines)
+	}()
+
+	// The script may write lines before Start returns, so wait for it before
+	// killing the script.
+	var fnErr error
+	started := make(chan struct{})
+	handled := make(chan struct{})
+	stop := make(chan struct{})
+	go func() {
+		defer close(handled)
+		for line := range lines {
+			if fnErr != nil {
+				// Keep reading so the process isn't blocked on a full pipe.
+				continue
+			}
+			if fnErr = fn(line); fnErr != nil {
+				<-started
+				killCmd(cmd, res)
+				close(stop)
+			}
+		}
+	}()
+
+	res = &Result{Script: script}
+	wait := sh.begin(cmd, res)
+	close(started)
+	wait()
+	outW.Close()
+	if errW != nil {
+		errW.Close()
+	}
+	closePipes := func() {
+		for _, r := range pipes {
+			r.Close()
+		}
+	}
+	select {
+	case <-handled:
+		copying.Wait()
+		closePipes()
+	case <-stop:
+		closePipes()
+		<-handled
+		copying.Wait()
+	}
+	res.err = wrapExitError(res.err, script, cmd.Dir, tail.String())
+	traced := *res
+	traced.Stdout = sh.trim([]byte(output[1].String()))
+	traced.Stderr = sh.trim([]byte(output[2].String()))
+	sh.trace(TraceFinish, cmd.Dir, &traced)
+
+	if fnErr != nil {
+		// The callback asked us to stop, so the script being killed is expected.
+		res.err = fnErr
+		return res, true
+	}
+	return res, false
+}
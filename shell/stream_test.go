@@ -0,0 +1,68 @@
+package shell
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLines(t *testing.T) {
+	sh := &Shell{}
+	var lines []string
+	err := sh.Lines(`echo one; echo two >&2; printf three`, func(line string) error {
+		lines = append(lines, line)
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(lines, []string{"one", "three"}) {
+		t.Errorf("Lines(...) -> %v, %#v", err, lines)
+	}
+
+	stop := errors.New("stop")
+	start := time.Now()
+	err = sh.Lines(`echo ready; sleep 10`, func(line string) error {
+		return stop
+	})
+	if err != stop {
+		t.Errorf("Lines(...) -> %v != %v", err, stop)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Lines should kill the script when the callback fails")
+	}
+
+	// sleep isn't killed with the script, and keeps Stdout open.
+	start = time.Now()
+	err = sh.Lines(`echo ready; sleep 10; echo done`, func(line string) error {
+		return stop
+	})
+	if err != stop || time.Since(start) > 5*time.Second {
+		t.Errorf("Lines(...) with a child process -> %v after %v", err, time.Since(start))
+	}
+}
+
+func TestStream(t *testing.T) {
+	sh := &Shell{}
+	lines, res := sh.Stream(`echo out; sleep 0.05; echo err >&2; exit 4`)
+	var got []Line
+	for line := range lines {
+		got = append(got, line)
+	}
+	if len(got) != 2 || got[0].Text != "out" || got[0].IsStderr() || got[1].Text != "err" || !got[1].IsStderr() {
+		t.Errorf("Stream(...) -> %#v", got)
+	}
+	if res.ExitCode != 4 || sh.LastError() == nil || sh.LastError().StderrTail != "err" {
+		t.Errorf("Stream(...) -> ExitCode %d, LastError %#v", res.ExitCode, sh.LastError())
+	}
+
+	lines, res = sh.Must().Stream(`echo out; exit 4`)
+	for range lines {
+	}
+	defer func() {
+		var exitErr *ExitError
+		if err, _ := recover().(error); !errors.As(err, &exitErr) || exitErr.ExitCode() != 4 {
+			t.Errorf("Must().Stream(...) Err() should panic with the ExitError, got %v", err)
+		}
+	}()
+	res.Err()
+	t.Errorf("Must().Stream(...) Err() should panic")
+}
@@ -1,8 +1,11 @@
 package shell
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
-	"regexp"
+	"reflect"
+	"strings"
 )
 
 // Lookuper is an environment that can be used for simple templating with StringTemplate.
@@ -27,18 +30,8 @@ func (vars Vars) Lookup(name string) (interface{}, error) {
 	return nil, fmt.Errorf("%q not in %#v", name, vars)
 }
 
-const openDelim = `#{`
-const closeDelim = `}`
-const spaces = ` *`
-const name = `(?P<name>[\w-_]+)`
-const raw = `(?P<raw>raw +)?`
-
-var openDelimQ = regexp.QuoteMeta(openDelim)
-var closeDelimQ = regexp.QuoteMeta(closeDelim)
-var matcher = regexp.MustCompile(openDelimQ + spaces + raw + name + spaces + closeDelimQ)
-
 // ScriptTemplate renders a template of a shell script using the provided
-// variables.
+// variables. See ParseScriptTemplate for the template language.
 //
 // Each occurrence of `#{varName}` is replaced with occurrence of with the
 // corresponding value from the Vars map, converted to strings with Escape().
@@ -47,32 +40,315 @@ var matcher = regexp.MustCompile(openDelimQ + spaces + raw + name + spaces + clo
 // Occurences of `#{raw varName}` will be converted to strings with ToRaw if
 // necessary, but not escaped.
 //
-// ScriptTemplate panics if a varName is not found in vars.
+// ScriptTemplate panics if the template is invalid, or if a varName is not
+// found in vars.
+func ScriptTemplate(template string, vars Lookuper) string {
+	return scriptTemplate(nil, nil, template, vars)
+}
+
+// ScriptTemplate is like the ScriptTemplate function, but escapes values for
+// the shell's Interpreter.
 //
 // @StaticCompose.Group("formatters", "%st")
-func ScriptTemplate(template string, vars Lookuper) string {
-	used := make(map[string]bool)
-	return matcher.ReplaceAllStringFunc(template, func(match string) string {
-		submatch := matcher.FindStringSubmatch(match)
-		raw := submatch[1] != ""
-		name := submatch[2]
+func (sh *Shell) ScriptTemplate(template string, vars Lookuper) string {
+	return scriptTemplate(sh.quote(), sh.secrets(), template, vars)
+}
+
+func scriptTemplate(quote func(s string) string, secrets *secretSet, template string, vars Lookuper) string {
+	t, err := ParseScriptTemplate(template)
+	if err != nil {
+		panic(err)
+	}
+	t.quote = quote
+	t.secrets = secrets
+	return t.MustRender(vars)
+}
+
+// Template is a compiled script template, which can be rendered many times.
+type Template struct {
+	nodes []tmplNode
+	src   string
+	quote func(s string) string
+	// Secret values are added here when the template is rendered.
+	secrets *secretSet
+}
+
+// ParseScriptTemplate compiles a template of a shell script. Render the
+// template with different variables as many times as needed:
+//
+//   deploy, err := shell.ParseScriptTemplate(`kubectl apply -n #{NAMESPACE | default "prod"} -f #{FILE}`)
+//   for _, file := range files {
+//     sh.Run(deploy.MustRender(shell.Vars{"FILE": file}))
+//   }
+//
+// Every expansion is escaped according to where it is in the script, like
+// ScriptPrintf does, unless it starts with raw, or ends with the raw filter:
+//
+//   #{NAME}                   the value of NAME, escaped
+//   #{raw NAME}               the value of NAME, not escaped
+//   #{NAME | upper}           the value of NAME, passed through filters
+//   #{"literal" | base64}     a literal string or number, passed through filters
+//
+// The filters are:
+//
+//   default "value"  use "value" if the variable is missing or empty
+//   join ","         join the items of a slice with ","
+//   upper, lower     change the case of a string
+//   json             encode the value as JSON
+//   base64           encode the value as base64
+//   raw              don't escape the value
+//
+// Blocks repeat or skip part of the template. A block alone on its line
+// doesn't leave an empty line behind.
+//
+//   #{for f in FILES}
+//   gzip #{f}
+//   #{end}
+//   #{if VERBOSE}set -x#{else}set +x#{end}
+//   #{if not DRY_RUN}make install#{end}
+//
+// A condition is false if its variable is missing or the zero value, or an
+// empty slice, map or string. Errors are *TemplateErrors.
+//
+// Templates from before there were filters and blocks still work, including
+// variables named like keywords, where the keyword doesn't make sense: #{raw},
+// #{for} and #{if} alone, and #{else} and #{end} outside of a block. One thing
+// is incompatible: text between #{ and } that isn't a valid expansion, like
+// #{a b}, used to be left as it was, and is now an error. Write #{raw "#{"}
+// for a literal #{.
+func ParseScriptTemplate(template string) (*Template, error) {
+	nodes, err := parseTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{nodes: nodes, src: template}, nil
+}
+
+// Interp returns a copy of the template that escapes values for interp,
+// instead of for Bash.
+func (t *Template) Interp(interp *Interpreter) *Template {
+	copied := *t
+	copied.quote = interp.Quote
+	return &copied
+}
+
+// Render renders the template using the provided variables. It returns an
+// error if a variable is not found in vars, or a filter can't be applied to
+// it.
+func (t *Template) Render(vars Lookuper) (string, error) {
+	if vars == nil {
+		vars = Vars{}
+	}
+	r := &tmplRenderer{t: t, out: &scriptWriter{quote: t.quote, secrets: t.secrets}}
+	if err := r.walk(t.nodes, vars); err != nil {
+		return "", err
+	}
+	return r.out.script()
+}
+
+// MustRender is like Render, but panics on error.
+func (t *Template) MustRender(vars Lookuper) string {
+	script, err := t.Render(vars)
+	if err != nil {
+		panic(err)
+	}
+	return script
+}
+
+// String returns the source of the template.
+func (t *Template) String() string {
+	return t.src
+}
+
+type tmplRenderer struct {
+	t   *Template
+	out *scriptWriter
+}
+
+func (r *tmplRenderer) walk(nodes []tmplNode, vars Lookuper) error {
+	for _, node := range nodes {
+		if err := r.render(node, vars); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-		val, err := vars.Lookup(name)
+func (r *tmplRenderer) render(node tmplNode, vars Lookuper) error {
+	switch node := node.(type) {
+	case textNode:
+		r.out.writeText(string(node))
+	case *exprNode:
+		val, err := r.eval(node.pipe, node.pos, vars)
 		if err != nil {
-			panic(fmt.Errorf(`Template contained expansion for variable, but lookup failed: %q: %v`, name, err))
+			return err
 		}
+		if missing, ok := val.(missingValue); ok {
+			return missing.err
+		}
+		if node.raw {
+			if secret, ok := val.(Secret); ok {
+				r.out.secrets.add(string(secret))
+			}
+			val = ToRaw(val)
+		}
+		if err := r.out.writeValue(val); err != nil {
+			return newTemplateError(r.t.src, node.pos, err)
+		}
+	case *ifNode:
+		val, err := r.eval(node.pipe, node.pos, vars)
+		if err != nil {
+			return err
+		}
+		if truthy(val) != node.not {
+			return r.walk(node.then, vars)
+		}
+		return r.walk(node.els, vars)
+	case *forNode:
+		val, err := r.eval(node.pipe, node.pos, vars)
+		if err != nil {
+			return err
+		}
+		if missing, ok := val.(missingValue); ok {
+			return missing.err
+		}
+		list := reflect.ValueOf(val)
+		if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+			return newTemplateError(r.t.src, node.pos, fmt.Errorf("can't loop over %T", val))
+		}
+		for i := 0; i < list.Len(); i++ {
+			scope := loopScope{node.name, list.Index(i).Interface(), vars}
+			if err := r.walk(node.body, scope); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
 
-		used[name] = true
+// eval returns the value of pipe. Variables that are not found in vars are
+// missingValues, so that they can be replaced by default.
+func (r *tmplRenderer) eval(pipe *tmplPipe, pos int, vars Lookuper) (interface{}, error) {
+	val := lookupOperand(pipe.operand, vars)
+	for _, call := range pipe.filters {
+		args := make([]interface{}, len(call.args))
+		for i, arg := range call.args {
+			args[i] = lookupOperand(arg, vars)
+			if missing, ok := args[i].(missingValue); ok {
+				return nil, missing.err
+			}
+		}
+		if missing, ok := val.(missingValue); ok && call.name != "default" {
+			return nil, missing.err
+		}
 
-		var res string
-		if raw {
-			res = string(ToRaw(val))
-		} else {
-			res = string(Escape(val))
+		out, err := call.fn.apply(val, args)
+		if err != nil {
+			return nil, newTemplateError(r.t.src, pos, fmt.Errorf("%s: %v", call.name, err))
+		}
+		// Don't let filters reveal secrets.
+		if secret, ok := val.(Secret); ok {
+			switch raw := out.(type) {
+			case Secret:
+			case Raw:
+				r.out.secrets.add(string(secret), string(raw))
+			default:
+				out = Secret(ToRaw(out))
+			}
 		}
+		val = out
+	}
+	return val, nil
+}
+
+// missingValue is the value of a variable that was not found.
+type missingValue struct {
+	err error
+}
+
+func lookupOperand(operand tmplOperand, vars Lookuper) interface{} {
+	if operand.isLiteral {
+		return operand.value
+	}
+	val, err := vars.Lookup(operand.value)
+	if err != nil {
+		return missingValue{fmt.Errorf(`Template contained expansion for variable, but lookup failed: %q: %v`, operand.value, err)}
+	}
+	return val
+}
+
+// loopScope is the Lookuper inside a #{for} block.
+type loopScope struct {
+	name   string
+	val    interface{}
+	parent Lookuper
+}
+
+func (s loopScope) Lookup(name string) (interface{}, error) {
+	if name == s.name {
+		return s.val, nil
+	}
+	return s.parent.Lookup(name)
+}
 
-		return res
-	})
+// truthy returns true unless val is missing, or the zero value, or empty.
+func truthy(val interface{}) bool {
+	if _, ok := val.(missingValue); ok || val == nil {
+		return false
+	}
+	v := reflect.ValueOf(val)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.String, reflect.Chan:
+		return v.Len() > 0
+	}
+	return !v.IsZero()
+}
+
+// tmplFilter is a filter in a template pipe, like `| join ","`.
+type tmplFilter struct {
+	// Number of arguments.
+	args  int
+	apply func(val interface{}, args []interface{}) (interface{}, error)
+}
+
+var templateFilters = map[string]tmplFilter{
+	"default": {1, func(val interface{}, args []interface{}) (interface{}, error) {
+		if truthy(val) {
+			return val, nil
+		}
+		return args[0], nil
+	}},
+	"join": {1, func(val interface{}, args []interface{}) (interface{}, error) {
+		list := reflect.ValueOf(val)
+		if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+			return nil, fmt.Errorf("can't join %T", val)
+		}
+		items := make([]string, list.Len())
+		for i := range items {
+			items[i] = string(ToRaw(list.Index(i).Interface()))
+		}
+		return strings.Join(items, string(ToRaw(args[0]))), nil
+	}},
+	"upper": {0, func(val interface{}, args []interface{}) (interface{}, error) {
+		return strings.ToUpper(string(ToRaw(val))), nil
+	}},
+	"lower": {0, func(val interface{}, args []interface{}) (interface{}, error) {
+		return strings.ToLower(string(ToRaw(val))), nil
+	}},
+	"json": {0, func(val interface{}, args []interface{}) (interface{}, error) {
+		encoded, err := json.Marshal(val)
+		return string(encoded), err
+	}},
+	"base64": {0, func(val interface{}, args []interface{}) (interface{}, error) {
+		data, ok := val.([]byte)
+		if !ok {
+			data = []byte(ToRaw(val))
+		}
+		return base64.StdEncoding.EncodeToString(data), nil
+	}},
+	"raw": {0, func(val interface{}, args []interface{}) (interface{}, error) {
+		return ToRaw(val), nil
+	}},
 }
 
 func templateTest() {
@@ -0,0 +1,445 @@
+package shell
+
+// Parsing of script templates. See ParseScriptTemplate.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const openDelim = `#{`
+const closeDelim = `}`
+
+// TemplateError describes a problem with a script template, and where it is.
+type TemplateError struct {
+	// Position of the expansion with the problem, starting from 1.
+	Line, Col int
+	Err       error
+}
+
+func (e *TemplateError) Error() string {
+	return fmt.Sprintf("script template:%d:%d: %v", e.Line, e.Col, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// Nodes of a parsed template.
+type (
+	tmplNode interface{}
+
+	textNode string
+
+	// #{pipe} or #{raw pipe}
+	exprNode struct {
+		pos  int
+		pipe *tmplPipe
+		raw  bool
+	}
+
+	// #{for name in pipe} body #{end}
+	forNode struct {
+		pos  int
+		name string
+		pipe *tmplPipe
+		body []tmplNode
+	}
+
+	// #{if pipe} then #{else} els #{end}
+	ifNode struct {
+		pos  int
+		not  bool
+		pipe *tmplPipe
+		then []tmplNode
+		els  []tmplNode
+	}
+)
+
+// tmplPipe is a value followed by filters, like `NAMESPACE | default "prod"`.
+type tmplPipe struct {
+	operand tmplOperand
+	filters []tmplFilterCall
+}
+
+// tmplOperand is a variable name, or a literal if isLiteral.
+type tmplOperand struct {
+	value     string
+	isLiteral bool
+}
+
+type tmplFilterCall struct {
+	name string
+	fn   tmplFilter
+	args []tmplOperand
+}
+
+// tmplItem is a piece of template source: either text, or the contents of an
+// expansion.
+type tmplItem struct {
+	pos    int
+	text   string
+	action bool
+	words  []tmplWord
+	// If true, the expansion starts, continues or ends a block.
+	block bool
+	// If true, text starts at the beginning of a line.
+	lineStart bool
+}
+
+// tmplWord is a word in an expansion: a name, a quoted string, or "|".
+type tmplWord struct {
+	pos    int
+	value  string
+	quoted bool
+}
+
+// parseTemplate parses src into a list of nodes.
+func parseTemplate(src string) ([]tmplNode, error) {
+	items, err := lexTemplate(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &tmplParser{src: src, items: items}
+	nodes, end, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	if end != nil {
+		return nil, p.errorf(end.pos, "unexpected #{%s}", end.words[0].value)
+	}
+	return nodes, nil
+}
+
+// lexTemplate splits src into text and expansions. Blocks alone on their line
+// take the whole line with them.
+func lexTemplate(src string) ([]*tmplItem, error) {
+	var items []*tmplItem
+	pos := 0
+	for pos < len(src) {
+		start := strings.Index(src[pos:], openDelim)
+		if start < 0 {
+			items = append(items, &tmplItem{pos: pos, text: src[pos:]})
+			break
+		}
+		start += pos
+		if start > pos {
+			items = append(items, &tmplItem{pos: pos, text: src[pos:start]})
+		}
+		words, end, err := lexAction(src, start+len(openDelim))
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, &tmplItem{pos: start, action: true, words: words})
+		pos = end
+	}
+
+	markBlocks(items)
+	for i, item := range items {
+		if item.block {
+			trimStandalone(items, i)
+		}
+	}
+	return items, nil
+}
+
+// lexAction splits the expansion starting at pos into words, and returns the
+// position after its closing delimiter.
+func lexAction(src string, pos int) ([]tmplWord, int, error) {
+	var words []tmplWord
+	for pos < len(src) {
+		c := rune(src[pos])
+		switch {
+		case strings.HasPrefix(src[pos:], closeDelim):
+			return words, pos + len(closeDelim), nil
+		case c == ' ' || c == '\t':
+			pos++
+		case c == '|':
+			words = append(words, tmplWord{pos: pos, value: "|"})
+			pos++
+		case c == '"':
+			end := pos + 1
+			for end < len(src) && src[end] != '"' {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, 0, newTemplateError(src, pos, fmt.Errorf("unterminated string"))
+			}
+			value, err := strconv.Unquote(src[pos : end+1])
+			if err != nil {
+				return nil, 0, newTemplateError(src, pos, err)
+			}
+			words = append(words, tmplWord{pos: pos, value: value, quoted: true})
+			pos = end + 1
+		case isNameRune(c):
+			end := pos
+			for end < len(src) && isNameRune(rune(src[end])) {
+				end++
+			}
+			words = append(words, tmplWord{pos: pos, value: src[pos:end]})
+			pos = end
+		default:
+			return nil, 0, newTemplateError(src, pos, fmt.Errorf("unexpected %q in expansion", c))
+		}
+	}
+	return nil, 0, newTemplateError(src, pos, fmt.Errorf("unclosed %s", openDelim))
+}
+
+func isNameRune(c rune) bool {
+	return c == '_' || c == '-' || c == '.' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// keyword returns the first word of an expansion, unless it is quoted.
+func (item *tmplItem) keyword() string {
+	if !item.action || len(item.words) == 0 || item.words[0].quoted {
+		return ""
+	}
+	return item.words[0].value
+}
+
+// markBlocks marks the expansions that start, continue or end a block. Like
+// in templates from before there were blocks, a keyword alone is a variable
+// where it can't be part of a block: #{for} and #{if} anywhere, and #{else}
+// and #{end} outside of a block.
+func markBlocks(items []*tmplItem) {
+	depth := 0
+	for _, item := range items {
+		switch item.keyword() {
+		case "for", "if":
+			if len(item.words) > 1 {
+				item.block = true
+				depth++
+			}
+		case "else":
+			item.block = depth > 0
+		case "end":
+			if depth > 0 {
+				item.block = true
+				depth--
+			}
+		}
+	}
+}
+
+// trimStandalone removes the line around items[i] if it is the only thing on
+// its line.
+func trimStandalone(items []*tmplItem, i int) {
+	var before, after *tmplItem
+	if i > 0 {
+		before = items[i-1]
+		if before.action {
+			return
+		}
+	}
+	if i+1 < len(items) {
+		after = items[i+1]
+		if after.action {
+			return
+		}
+	}
+
+	if before != nil {
+		lineStart := strings.LastIndexByte(before.text, '\n') + 1
+		if lineStart == 0 && i > 1 && !before.lineStart {
+			return
+		}
+		if strings.TrimLeft(before.text[lineStart:], " \t") != "" {
+			return
+		}
+	}
+	lineEnd := -1
+	if after != nil {
+		lineEnd = strings.IndexByte(after.text, '\n')
+		if lineEnd < 0 && i+2 < len(items) {
+			return
+		}
+		rest := after.text
+		if lineEnd >= 0 {
+			rest = after.text[:lineEnd]
+		}
+		if strings.TrimRight(rest, " \t\r") != "" {
+			return
+		}
+	}
+
+	if before != nil {
+		before.text = before.text[:strings.LastIndexByte(before.text, '\n')+1]
+	}
+	if after != nil {
+		if lineEnd < 0 {
+			lineEnd = len(after.text) - 1
+		}
+		after.pos += lineEnd + 1
+		after.text = after.text[lineEnd+1:]
+		after.lineStart = true
+	}
+}
+
+type tmplParser struct {
+	src   string
+	items []*tmplItem
+	next  int
+}
+
+func newTemplateError(src string, pos int, err error) *TemplateError {
+	line := strings.Count(src[:pos], "\n") + 1
+	col := pos - strings.LastIndexByte(src[:pos], '\n')
+	return &TemplateError{Line: line, Col: col, Err: err}
+}
+
+func (p *tmplParser) errorf(pos int, format string, args ...interface{}) error {
+	return newTemplateError(p.src, pos, fmt.Errorf(format, args...))
+}
+
+// parseList parses nodes until the end of the template, or an #{else} or
+// #{end}, which is returned.
+func (p *tmplParser) parseList() (nodes []tmplNode, end *tmplItem, err error) {
+	for p.next < len(p.items) {
+		item := p.items[p.next]
+		p.next++
+		if !item.action {
+			if item.text != "" {
+				nodes = append(nodes, textNode(item.text))
+			}
+			continue
+		}
+		if len(item.words) == 0 {
+			return nil, nil, p.errorf(item.pos, "empty expansion")
+		}
+
+		keyword := item.keyword()
+		if !item.block && keyword != "raw" {
+			keyword = ""
+		}
+		var node tmplNode
+		switch {
+		case keyword == "else" || keyword == "end":
+			if len(item.words) > 1 {
+				return nil, nil, p.errorf(item.words[1].pos, "unexpected %q after %s", item.words[1].value, keyword)
+			}
+			return nodes, item, nil
+		case keyword == "for":
+			node, err = p.parseFor(item)
+		case keyword == "if":
+			node, err = p.parseIf(item)
+		case keyword == "raw" && len(item.words) > 1 && !(item.words[1].value == "|" && !item.words[1].quoted):
+			// #{raw} and #{raw | filter} expand a variable named raw.
+			var pipe *tmplPipe
+			pipe, err = p.parsePipe(item.words[1:], item.pos)
+			node = &exprNode{pos: item.pos, pipe: pipe, raw: true}
+		default:
+			var pipe *tmplPipe
+			pipe, err = p.parsePipe(item.words, item.pos)
+			node = &exprNode{pos: item.pos, pipe: pipe}
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil, nil
+}
+
+// parseFor parses `for name in pipe`, and the block after it.
+func (p *tmplParser) parseFor(item *tmplItem) (tmplNode, error) {
+	words := item.words
+	if len(words) < 4 || words[1].quoted || words[2].value != "in" || words[2].quoted {
+		return nil, p.errorf(item.pos, "expected #{for name in list}")
+	}
+	pipe, err := p.parsePipe(words[3:], item.pos)
+	if err != nil {
+		return nil, err
+	}
+	body, end, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	if end == nil || end.words[0].value != "end" {
+		return nil, p.errorf(item.pos, "#{for} without #{end}")
+	}
+	return &forNode{pos: item.pos, name: words[1].value, pipe: pipe, body: body}, nil
+}
+
+// parseIf parses `if [not] pipe`, and the blocks after it.
+func (p *tmplParser) parseIf(item *tmplItem) (tmplNode, error) {
+	node := &ifNode{pos: item.pos}
+	words := item.words[1:]
+	if len(words) > 0 && words[0].value == "not" && !words[0].quoted {
+		node.not = true
+		words = words[1:]
+	}
+	pipe, err := p.parsePipe(words, item.pos)
+	if err != nil {
+		return nil, err
+	}
+	node.pipe = pipe
+
+	then, end, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	node.then = then
+	if end != nil && end.words[0].value == "else" {
+		node.els, end, err = p.parseList()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if end == nil || end.words[0].value != "end" {
+		return nil, p.errorf(item.pos, "#{if} without #{end}")
+	}
+	return node, nil
+}
+
+// parsePipe parses `operand | filter args... | ...`.
+func (p *tmplParser) parsePipe(words []tmplWord, pos int) (*tmplPipe, error) {
+	if len(words) == 0 {
+		return nil, p.errorf(pos, "missing value")
+	}
+	if words[0].value == "|" && !words[0].quoted {
+		return nil, p.errorf(words[0].pos, "missing value before |")
+	}
+	pipe := &tmplPipe{operand: operandOf(words[0])}
+	words = words[1:]
+	for len(words) > 0 {
+		if words[0].value != "|" || words[0].quoted {
+			return nil, p.errorf(words[0].pos, "unexpected %q, expected |", words[0].value)
+		}
+		if len(words) == 1 || words[1].quoted {
+			return nil, p.errorf(words[0].pos, "missing filter after |")
+		}
+		call := tmplFilterCall{name: words[1].value}
+		namePos := words[1].pos
+		filter, ok := templateFilters[call.name]
+		if !ok {
+			return nil, p.errorf(namePos, "unknown filter %q", call.name)
+		}
+		call.fn = filter
+		words = words[2:]
+		for len(words) > 0 && !(words[0].value == "|" && !words[0].quoted) {
+			call.args = append(call.args, operandOf(words[0]))
+			words = words[1:]
+		}
+		if len(call.args) != filter.args {
+			arguments := "arguments"
+			if filter.args == 1 {
+				arguments = "argument"
+			}
+			return nil, p.errorf(namePos, "filter %q takes %d %s, not %d", call.name, filter.args, arguments, len(call.args))
+		}
+		pipe.filters = append(pipe.filters, call)
+	}
+	return pipe, nil
+}
+
+// operandOf returns the operand for a word. Quoted strings and numbers are
+// literals, other words are variable names.
+func operandOf(word tmplWord) tmplOperand {
+	literal := word.quoted || '0' <= word.value[0] && word.value[0] <= '9'
+	return tmplOperand{value: word.value, isLiteral: literal}
+}
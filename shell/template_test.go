@@ -40,3 +40,100 @@ func TestScriptTemplatePanics(t *testing.T) {
 	ScriptTemplate("foo #{ok} bar #{notokay}", Vars{"ok": 1})
 	t.Errorf("ScriptTemplate should panic")
 }
+
+func TestScriptTemplateLanguage(t *testing.T) {
+	cases := []struct {
+		tmpl string
+		vars Vars
+		out  string
+	}{
+		{`kubectl -n #{NAMESPACE | default "prod"}`, Vars{}, `kubectl -n prod`},
+		{`kubectl -n #{NAMESPACE | default "prod"}`, Vars{"NAMESPACE": "dev"}, `kubectl -n dev`},
+		{`echo #{NAME | upper}`, Vars{"NAME": "a b"}, `echo 'A B'`},
+		{`echo #{FILES | join ","}`, Vars{"FILES": []string{"a b", "c"}}, `echo 'a b,c'`},
+		{`echo #{V | json}`, Vars{"V": map[string]int{"a": 1}}, `echo '{"a":1}'`},
+		{`echo #{"hi" | base64}`, nil, `echo aGk=`},
+		{`echo #{V | upper | raw}`, Vars{"V": "$x"}, `echo $X`},
+		{`echo #{V | default 3}`, Vars{"V": ""}, `echo 3`},
+		{`rm#{for f in FILES} #{f}#{end}`, Vars{"FILES": []string{"a b", "c"}}, `rm 'a b' c`},
+		{"#{for f in FILES}\n  gzip #{f}\n#{end}\ndone", Vars{"FILES": []string{"a", "b"}}, "  gzip a\n  gzip b\ndone"},
+		{"set -e\n#{if VERBOSE}\nset -x\n#{else}\nset +x\n#{end}\n", Vars{"VERBOSE": true}, "set -e\nset -x\n"},
+		{"#{if VERBOSE}set -x#{else}set +x#{end}", Vars{}, "set +x"},
+		{"#{if not FILES}echo none#{end}", Vars{"FILES": []string{}}, "echo none"},
+		{`echo #{f}#{for f in FILES}#{f}#{end}`, Vars{"f": 1, "FILES": []int{2}}, `echo 12`},
+		{`echo #{raw} #{raw | upper}`, Vars{"raw": "a b"}, `echo 'a b' 'A B'`},
+		{`echo #{for} #{if} #{else} #{end}`, Vars{"for": 1, "if": 2, "else": 3, "end": 4}, `echo 1 2 3 4`},
+		{"#{if X}\n#{end}\n#{end}", Vars{"X": true, "end": "x"}, "x"},
+		{`echo #{raw "#{"}a b}`, nil, `echo #{a b}`},
+	}
+
+	for _, c := range cases {
+		tmpl, err := ParseScriptTemplate(c.tmpl)
+		if err != nil {
+			t.Errorf("ParseScriptTemplate(%q) -> %v", c.tmpl, err)
+			continue
+		}
+		var vars Lookuper
+		if c.vars != nil {
+			vars = c.vars
+		}
+		actual, err := tmpl.Render(vars)
+		if err != nil || actual != c.out {
+			t.Errorf("Render(%q, %#v) -> %q, %v != %q", c.tmpl, c.vars, actual, err, c.out)
+		}
+	}
+}
+
+func TestParseScriptTemplateErrors(t *testing.T) {
+	cases := []struct {
+		tmpl string
+		err  string
+	}{
+		{`echo #{NAME`, `script template:1:12: unclosed #{`},
+		{"echo\n#{for f FILES}#{end}", `script template:2:1: expected #{for name in list}`},
+		{`#{if X}yes`, `script template:1:1: #{if} without #{end}`},
+		{`#{if X}#{else}#{else}#{end}`, `script template:1:1: #{if} without #{end}`},
+		{`#{X | shout}`, `script template:1:7: unknown filter "shout"`},
+		{`#{X | join}`, `script template:1:7: filter "join" takes 1 argument, not 0`},
+		{`#{X | upper 1}`, `script template:1:7: filter "upper" takes 0 arguments, not 1`},
+		{`#{X ; rm -rf /}`, `script template:1:5: unexpected ';' in expansion`},
+	}
+
+	for _, c := range cases {
+		_, err := ParseScriptTemplate(c.tmpl)
+		if err == nil || err.Error() != c.err {
+			t.Errorf("ParseScriptTemplate(%q) -> %v != %s", c.tmpl, err, c.err)
+		}
+	}
+}
+
+func TestTemplateRenderMany(t *testing.T) {
+	tmpl, err := ParseScriptTemplate(`gzip #{FILE}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"a", "b c"} {
+		expected := ScriptPrintf(`gzip %s`, file)
+		if actual := tmpl.MustRender(Vars{"FILE": file}); actual != expected {
+			t.Errorf("MustRender(FILE=%q) -> %q != %q", file, actual, expected)
+		}
+	}
+	if _, err := tmpl.Render(Vars{}); err == nil {
+		t.Errorf("Render without FILE should fail")
+	}
+	if actual := tmpl.Interp(Python3).MustRender(Vars{"FILE": "a"}); actual != `gzip "a"` {
+		t.Errorf("Interp(Python3).MustRender -> %q", actual)
+	}
+}
+
+func TestTemplateSecretFilters(t *testing.T) {
+	sh := &Shell{}
+	tmpl := `curl -H #{TOKEN | upper}`
+	script := sh.ScriptTemplate(tmpl, Vars{"TOKEN": Secret("hunter2")})
+	if script != "curl -H HUNTER2" {
+		t.Errorf("ScriptTemplate(%q) -> %q", tmpl, script)
+	}
+	if masked := sh.maskSecrets(script); masked != "curl -H ***" {
+		t.Errorf("maskSecrets(%q) -> %q", script, masked)
+	}
+}
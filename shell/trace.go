@@ -0,0 +1,275 @@
+package shell
+
+// Tracing records which scripts a Shell runs, like `set -x` in Bash.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of TraceEvent.
+const (
+	TraceStart  = "start"
+	TraceFinish = "finish"
+)
+
+// TraceEvent describes a script that is starting or has finished. Any secrets
+// are masked before the event is passed to a Tracer.
+type TraceEvent struct {
+	// TraceStart or TraceFinish.
+	Kind   string `json:"kind"`
+	Script string `json:"script"`
+	Dir    string `json:"dir,omitempty"`
+	// Variables the script's environment adds to os.Environ.
+	Env   []string  `json:"env,omitempty"`
+	Start time.Time `json:"start"`
+	// The attempt this event is for, counting from 1. See Retries.
+	Attempt int `json:"attempt,omitempty"`
+	// The following are only set for TraceFinish events.
+	Duration time.Duration `json:"duration,omitempty"`
+	ExitCode int           `json:"exit_code"`
+	Error    string        `json:"error,omitempty"`
+	// Captured output, if any, only for Tracers made by TraceOutput. See
+	// Result.
+	Stdout string `json:"stdout,omitempty"`
+	Stderr string `json:"stderr,omitempty"`
+}
+
+// Tracer receives events for each script a Shell runs.
+type Tracer interface {
+	Trace(event TraceEvent)
+}
+
+// TracerFunc adapts a function to the Tracer interface.
+type TracerFunc func(event TraceEvent)
+
+// Trace implements Tracer.
+func (fn TracerFunc) Trace(event TraceEvent) {
+	fn(event)
+}
+
+// TraceOutput returns a Tracer that passes events to t along with the output
+// captured from scripts. Other Tracers receive events without Stdout and
+// Stderr, so that output, which may contain secrets the shell doesn't know
+// about, is only logged when asked for.
+//
+//   sh.Trace = shell.TraceOutput(shell.JSONTrace(logFile))
+func TraceOutput(t Tracer) Tracer {
+	return outputTracer{t}
+}
+
+type outputTracer struct {
+	Tracer
+}
+
+// sendTrace passes event to t, without the captured output unless t asked
+// for it. See TraceOutput.
+func sendTrace(t Tracer, event TraceEvent) {
+	if !tracesOutput(t) {
+		event.Stdout, event.Stderr = "", ""
+	}
+	t.Trace(event)
+}
+
+// tracesOutput returns true if t receives the captured output of scripts.
+func tracesOutput(t Tracer) bool {
+	switch t.(type) {
+	case outputTracer, *Cassette:
+		return true
+	}
+	return false
+}
+
+// XTrace returns a Tracer that prints each script to w before it runs,
+// prefixed with "+ ", like `set -x` in Bash. If w is nil, os.Stderr is used.
+func XTrace(w io.Writer) Tracer {
+	if w == nil {
+		w = os.Stderr
+	}
+	return TracerFunc(func(event TraceEvent) {
+		if event.Kind == TraceStart {
+			printXTrace(w, event.Script)
+		}
+	})
+}
+
+func printXTrace(w io.Writer, script string) {
+	fmt.Fprintf(w, "+ %s\n", strings.Replace(script, "\n", "\n+ ", -1))
+}
+
+// JSONTrace returns a Tracer that writes each event to w as a line of JSON.
+// Output of scripts is only written if it's wrapped with TraceOutput.
+func JSONTrace(w io.Writer) Tracer {
+	var mu sync.Mutex
+	encoder := json.NewEncoder(w)
+	return TracerFunc(func(event TraceEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		encoder.Encode(event)
+	})
+}
+
+// TraceRecorder is a Tracer that stores events in memory, which is useful for
+// testing.
+type TraceRecorder struct {
+	mu     sync.Mutex
+	events []TraceEvent
+}
+
+// Trace implements Tracer.
+func (r *TraceRecorder) Trace(event TraceEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+// Events returns a copy of the recorded events.
+func (r *TraceRecorder) Events() []TraceEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]TraceEvent(nil), r.events...)
+}
+
+// Scripts returns the script of each recorded TraceStart event, in order.
+func (r *TraceRecorder) Scripts() []string {
+	scripts := []string{}
+	for _, event := range r.Events() {
+		if event.Kind == TraceStart {
+			scripts = append(scripts, event.Script)
+		}
+	}
+	return scripts
+}
+
+// trace reports res to the shell's Tracer, if any.
+func (sh *Shell) trace(kind string, dir string, res *Result) {
+	if sh.Trace == nil {
+		return
+	}
+	mask := sh.maskSecrets
+	event := TraceEvent{
+		Kind:    kind,
+		Script:  mask(res.Script),
+		Dir:     dir,
+		Start:   res.Start,
+		Attempt: res.Attempts,
+	}
+	event.Env = sh.tracedEnv()
+	if kind == TraceFinish {
+		event.Duration = res.Duration()
+		event.ExitCode = res.ExitCode
+		if res.err != nil {
+			event.Error = mask(briefError(res.err))
+		}
+		event.Stdout = mask(res.Stdout)
+		event.Stderr = mask(res.Stderr)
+	}
+	sendTrace(sh.Trace, event)
+}
+
+// tracedEnv returns the variables the shell adds to the environment of
+// scripts, with secrets masked.
+func (sh *Shell) tracedEnv() []string {
+	if sh.Env == nil {
+		return nil
+	}
+	var env []string
+	for _, kv := range sh.Env.Environ() {
+		env = append(env, sh.maskSecrets(kv))
+	}
+	return env
+}
+
+// MaskSecret ensures that s will be masked in the trace output of the shell,
+// and of the shells derived from it. Secret values are masked automatically
+// when they are escaped by the shell's methods, like Runf, or are in its Env.
+// Raw values that contain secrets, and scripts formatted by the package's
+// functions, like ScriptPrintf, must be registered with MaskSecret.
+func (sh *Shell) MaskSecret(s string) {
+	sh.secrets().add(s)
+}
+
+// secrets returns the secrets masked in the shell's traces.
+func (sh *Shell) secrets() *secretSet {
+	return sh.state().secrets
+}
+
+// maskSecrets masks the shell's secrets, and the Secret values in its Env, in
+// s.
+func (sh *Shell) maskSecrets(s string) string {
+	s = sh.secrets().mask(s)
+	var env secretSet
+	env.add(envSecrets(sh.Env)...)
+	return env.mask(s)
+}
+
+// envSecrets returns the Secret values of env.
+func envSecrets(env Environer) []string {
+	var secrets []string
+	switch env := env.(type) {
+	case Vars:
+		for _, v := range env {
+			if secret, ok := v.(Secret); ok {
+				secrets = append(secrets, string(secret))
+			}
+		}
+	case layeredEnv:
+		for _, layer := range env {
+			secrets = append(secrets, envSecrets(layer)...)
+		}
+	}
+	return secrets
+}
+
+// secretSet is a list of secrets to mask, shared by a shell and the shells
+// derived from it. A nil set is empty, and ignores secrets added to it.
+type secretSet struct {
+	mu     sync.Mutex
+	values []string
+}
+
+// add adds secrets to the set.
+func (set *secretSet) add(secrets ...string) {
+	if set == nil {
+		return
+	}
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	for _, s := range secrets {
+		if s != "" && !set.has(s) {
+			set.values = append(set.values, s)
+		}
+	}
+	// Mask longer secrets first, so that escaped secrets are masked whole.
+	sort.Slice(set.values, func(i, j int) bool {
+		return len(set.values[i]) > len(set.values[j])
+	})
+}
+
+func (set *secretSet) has(s string) bool {
+	for _, v := range set.values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// mask replaces each secret in s with "***".
+func (set *secretSet) mask(s string) string {
+	if set == nil {
+		return s
+	}
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	for _, v := range set.values {
+		s = strings.Replace(s, v, "***", -1)
+	}
+	return s
+}
@@ -0,0 +1,78 @@
+package shell
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	rec := &TraceRecorder{}
+	sh := &Shell{Trace: rec}
+	sh.Runf("echo %s", "hello world")
+	sh.Exec("false")
+	sh.Pipe("echo a", "cat").Out()
+
+	scripts := []string{"echo 'hello world'", "false", "echo a | cat"}
+	if !reflect.DeepEqual(rec.Scripts(), scripts) {
+		t.Errorf("Scripts() -> %#v != %#v", rec.Scripts(), scripts)
+	}
+	events := rec.Events()
+	if len(events) != 6 || events[3].Kind != TraceFinish || events[3].ExitCode != 1 {
+		t.Errorf("Events() -> %#v", events)
+	}
+}
+
+func TestTraceMasksSecrets(t *testing.T) {
+	var out bytes.Buffer
+	sh := &Shell{Trace: JSONTrace(&out)}
+	sh.WithEnv(Vars{"TOKEN": Secret("hunter2")}).Outf(`curl -H %s https://example.com`, Secret("Authorization: hunter2"))
+
+	var event TraceEvent
+	if err := json.NewDecoder(&out).Decode(&event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Script != "curl -H *** https://example.com" {
+		t.Errorf("Script -> %q", event.Script)
+	}
+	if !reflect.DeepEqual(event.Env, []string{"TOKEN=***"}) {
+		t.Errorf("Env -> %#v", event.Env)
+	}
+}
+
+func TestTraceOutput(t *testing.T) {
+	var plain, withOutput bytes.Buffer
+	(&Shell{Trace: JSONTrace(&plain)}).Out(`echo private`)
+	(&Shell{Trace: TraceOutput(JSONTrace(&withOutput))}).Out(`echo private`)
+
+	if bytes.Contains(plain.Bytes(), []byte(`"stdout"`)) {
+		t.Errorf("JSONTrace logged output: %s", plain.String())
+	}
+	if !bytes.Contains(withOutput.Bytes(), []byte(`"stdout":"private"`)) {
+		t.Errorf("TraceOutput(JSONTrace(...)) did not log output: %s", withOutput.String())
+	}
+}
+
+func TestXTrace(t *testing.T) {
+	var out bytes.Buffer
+	sh := &Shell{Trace: XTrace(&out)}
+	sh.Run("true\ntrue")
+	if out.String() != "+ true\n+ true\n" {
+		t.Errorf("XTrace -> %q", out.String())
+	}
+}
+
+func TestTraceSecretsAreScoped(t *testing.T) {
+	rec := &TraceRecorder{}
+	sh := &Shell{Trace: rec}
+	sh.Outf(`echo %s`, Secret("a"))
+	(&Shell{Trace: rec}).Run(`echo abc`)
+	sh.MaskSecret("abc")
+	sh.With().Run(`echo abc`)
+
+	scripts := []string{"echo ***", "echo abc", "echo ***"}
+	if !reflect.DeepEqual(rec.Scripts(), scripts) {
+		t.Errorf("Scripts() -> %#v != %#v", rec.Scripts(), scripts)
+	}
+}